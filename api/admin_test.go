@@ -0,0 +1,107 @@
+package api
+
+import (
+	"apiGo/storage"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// adminConfig returns DefaultConfig with the admin endpoints enabled under key.
+func adminConfig(key string) Config {
+	config := DefaultConfig()
+	config.AdminAPIKey = key
+	return config
+}
+
+func TestAdminConfigRedactsSecrets(t *testing.T) {
+	server, _ := newTestServer(t, adminConfig("s3cret"))
+
+	w := serve(server, http.MethodGet, "/admin/config", "", "Authorization", "Bearer s3cret")
+	expectStatus(t, w, http.StatusOK)
+
+	var body map[string]any
+	decode(t, w, &body)
+	for _, key := range []string{"writeTimeout", "shutdownTimeout", "maxResponseBytes", "longPollMaxTimeout", "features"} {
+		if _, found := body[key]; !found {
+			t.Errorf("expected the configuration to have %q", key)
+		}
+	}
+	if body["adminApiKey"] != redactedValue {
+		t.Errorf("expected the admin API key to be redacted, got %v", body["adminApiKey"])
+	}
+}
+
+func TestAdminConfigRequiresTheKey(t *testing.T) {
+	server, _ := newTestServer(t, adminConfig("s3cret"))
+
+	w := serve(server, http.MethodGet, "/admin/config", "", "Authorization", "Bearer guess")
+	expectStatus(t, w, http.StatusUnauthorized)
+
+	server, _ = newTestServer(t, DefaultConfig())
+	w = serve(server, http.MethodGet, "/admin/config", "")
+	expectStatus(t, w, http.StatusForbidden)
+}
+
+func TestRenameSubstringReplacesInTheField(t *testing.T) {
+	server, db := newTestServer(t, adminConfig("s3cret"), storage.NewProduct("OldCo Desk", "DSK-1"), storage.NewProduct("OldCo Chair", "CHR-1"), storage.NewProduct("Lamp", "LMP-1"))
+
+	w := serve(server, http.MethodPost, "/admin/renameSubstring", `{"field":"name","from":"OldCo","to":"NewCo"}`, "Authorization", "Bearer s3cret")
+	expectStatus(t, w, http.StatusOK)
+
+	var body RenameSubstringResponse
+	decode(t, w, &body)
+	if body.Affected != 2 {
+		t.Errorf("expected 2 affected products, got %d", body.Affected)
+	}
+	for id, name := range map[int64]string{1: "NewCo Desk", 2: "NewCo Chair", 3: "Lamp"} {
+		product, err := db.GetProductById(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if product.Name != name {
+			t.Errorf("expected product %d to be named %q, got %q", id, name, product.Name)
+		}
+	}
+}
+
+func TestRenameSubstringRejectsOtherFields(t *testing.T) {
+	server, _ := newTestServer(t, adminConfig("s3cret"), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodPost, "/admin/renameSubstring", `{"field":"status","from":"active","to":"draft"}`, "Authorization", "Bearer s3cret")
+	expectStatus(t, w, http.StatusBadRequest)
+
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "field status can't be renamed. Allowed: "+strings.Join(storage.ReplaceableFields, ", ") {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+}
+
+func TestExplainReturnsTheListingQuery(t *testing.T) {
+	server, _ := newTestServer(t, adminConfig("s3cret"))
+
+	w := serve(server, http.MethodGet, "/getProducts?explain=true&status=inactive&idFrom=5&limit=10&onlyDuplicates=true", "", "Authorization", "Bearer s3cret")
+	expectStatus(t, w, http.StatusOK)
+
+	var body ExplainProductsResponse
+	decode(t, w, &body)
+	query := "select id, name, code, createdAt, coalesce(updatedAt, createdAt), status, view_count from product" +
+		" where status = $1 and code in (select code from product group by code having count(*) > 1) and id >= $2" +
+		" order by code, id limit $3"
+	if body.Query != query {
+		t.Errorf("unexpected query: %s", body.Query)
+	}
+	if want := []any{"inactive", 5.0, 10.0}; !slices.Equal(body.Args, want) {
+		t.Errorf("expected the args %v, got %v", want, body.Args)
+	}
+}
+
+func TestExplainRequiresTheAdminKey(t *testing.T) {
+	server, _ := newTestServer(t, adminConfig("s3cret"))
+
+	w := serve(server, http.MethodGet, "/getProducts?explain=true", "")
+	expectStatus(t, w, http.StatusUnauthorized)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// Priorities of the requests waiting for admission. Queued reads are always admitted before queued writes.
+const (
+	priorityRead = iota
+	priorityWrite
+	priorityCount
+)
+
+// bulkPaths are the write endpoints handling many products at once, weighted Config.BulkRequestWeight.
+var bulkPaths = []string{"/importProducts", "/validateProducts"}
+
+// admissionExemptPaths are served without admission. Long polls spend their time idle, and would
+// otherwise hold capacity for their whole timeout.
+var admissionExemptPaths = []string{"/changes/longpoll"}
+
+// AdmissionStats reports the state of the admission queue, for the status endpoint.
+type AdmissionStats struct {
+	Capacity     int `json:"capacity"`
+	InUse        int `json:"inUse"`        // Weight of the requests being served.
+	QueuedReads  int `json:"queuedReads"`  // Read requests waiting to be admitted.
+	QueuedWrites int `json:"queuedWrites"` // Write requests waiting to be admitted.
+}
+
+// admissionWaiter is a request waiting in an admissionQueue.
+type admissionWaiter struct {
+	weight   int
+	admitted chan struct{} // Closed once the request is admitted.
+}
+
+// admissionQueue is a weighted semaphore with a queue per priority. When saturated, units freed are given
+// to the queued reads first, in arrival order, and to the queued writes only once no read is waiting.
+type admissionQueue struct {
+	mu       sync.Mutex
+	capacity int
+	used     int
+	queues   [priorityCount][]*admissionWaiter
+}
+
+// newAdmissionQueue creates an admissionQueue serving requests weighing capacity at most at once.
+func newAdmissionQueue(capacity int) *admissionQueue {
+	return &admissionQueue{capacity: capacity}
+}
+
+// acquire waits until weight units are free, after the requests of the same or a higher priority queued
+// ahead, or until ctx is done. weight must not exceed the capacity.
+func (o *admissionQueue) acquire(ctx context.Context, priority, weight int) error {
+	o.mu.Lock()
+	if o.used+weight <= o.capacity && o.queuedAhead(priority) == 0 {
+		o.used += weight
+		o.mu.Unlock()
+		return nil
+	}
+	waiter := &admissionWaiter{weight: weight, admitted: make(chan struct{})}
+	o.queues[priority] = append(o.queues[priority], waiter)
+	o.mu.Unlock()
+
+	select {
+	case <-waiter.admitted:
+		return nil
+	case <-ctx.Done():
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		select {
+		case <-waiter.admitted:
+			// Admitted in between, the units are given back.
+			o.used -= weight
+		default:
+			o.queues[priority] = slices.DeleteFunc(o.queues[priority], func(w *admissionWaiter) bool { return w == waiter })
+		}
+		// Either way the requests queued behind may fit now.
+		o.admit()
+		return ctx.Err()
+	}
+}
+
+// release gives back the weight units of a request admitted by acquire.
+func (o *admissionQueue) release(weight int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.used -= weight
+	o.admit()
+}
+
+// queuedAhead counts the requests queued with priority or a higher one. The caller holds mu.
+func (o *admissionQueue) queuedAhead(priority int) int {
+	queued := 0
+	for p := 0; p <= priority; p++ {
+		queued += len(o.queues[p])
+	}
+	return queued
+}
+
+// admit admits the queued requests that fit, by priority then arrival. A request that doesn't fit
+// blocks the ones behind it, so heavy requests aren't starved by lighter ones. The caller holds mu.
+func (o *admissionQueue) admit() {
+	for priority := range o.queues {
+		for len(o.queues[priority]) > 0 {
+			waiter := o.queues[priority][0]
+			if o.used+waiter.weight > o.capacity {
+				return
+			}
+			o.used += waiter.weight
+			close(waiter.admitted)
+			o.queues[priority] = o.queues[priority][1:]
+		}
+	}
+}
+
+// stats returns the current state of the queue.
+func (o *admissionQueue) stats() *AdmissionStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return &AdmissionStats{
+		Capacity:     o.capacity,
+		InUse:        o.used,
+		QueuedReads:  len(o.queues[priorityRead]),
+		QueuedWrites: len(o.queues[priorityWrite]),
+	}
+}
+
+// interceptAdmission is a middleware that admits the requests of the endpoint through the admission queue,
+// when Config.MaxConcurrentRequests is set. Reads weigh 1 and are served first, writes weigh 1, or
+// Config.BulkRequestWeight for bulk endpoints. Requests not admitted within Config.AdmissionQueueTimeout
+// are answered with 503.
+func (o *Server) interceptAdmission(method, path string, f apiFunc) apiFunc {
+	if o.admission == nil || slices.Contains(admissionExemptPaths, path) {
+		return f
+	}
+
+	priority, weight := priorityRead, 1
+	if method != http.MethodGet && method != http.MethodHead {
+		priority = priorityWrite
+		if slices.Contains(bulkPaths, path) {
+			weight = min(o.config.BulkRequestWeight, o.config.MaxConcurrentRequests)
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx, cancel := context.WithTimeout(r.Context(), o.config.AdmissionQueueTimeout)
+		defer cancel()
+
+		if err := o.admission.acquire(ctx, priority, weight); err != nil {
+			return newAPIError(http.StatusServiceUnavailable, "service.saturated")
+		}
+		defer o.admission.release(weight)
+
+		return f(w, r)
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until the queue holds the given numbers of reads and writes.
+func waitQueued(t *testing.T, queue *admissionQueue, reads, writes int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := queue.stats()
+		if stats.QueuedReads == reads && stats.QueuedWrites == writes {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d queued reads and %d queued writes, got %+v", reads, writes, stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAdmissionServesQueuedReadsBeforeQueuedWrites(t *testing.T) {
+	queue := newAdmissionQueue(1)
+	if err := queue.acquire(context.Background(), priorityWrite, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan string, 2)
+	enqueue := func(name string, priority int) {
+		go func() {
+			if err := queue.acquire(context.Background(), priority, 1); err != nil {
+				t.Error(err)
+				return
+			}
+			admitted <- name
+		}()
+	}
+	// The write arrives first, but the read is admitted ahead of it once the system frees up.
+	enqueue("write", priorityWrite)
+	waitQueued(t, queue, 0, 1)
+	enqueue("read", priorityRead)
+	waitQueued(t, queue, 1, 1)
+
+	queue.release(1)
+	if first := <-admitted; first != "read" {
+		t.Fatalf("expected the read to be admitted first, got the %s", first)
+	}
+	if stats := queue.stats(); stats.InUse != 1 || stats.QueuedWrites != 1 {
+		t.Errorf("expected the write to stay queued, got %+v", stats)
+	}
+
+	queue.release(1)
+	if second := <-admitted; second != "write" {
+		t.Fatalf("expected the write to be admitted next, got the %s", second)
+	}
+	queue.release(1)
+}
+
+func TestAdmissionGivesUpOnceTheContextIsDone(t *testing.T) {
+	queue := newAdmissionQueue(1)
+	if err := queue.acquire(context.Background(), priorityRead, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := queue.acquire(ctx, priorityWrite, 1); err == nil {
+		t.Fatal("expected the write to give up while the queue is saturated")
+	}
+	if stats := queue.stats(); stats.QueuedWrites != 0 || stats.InUse != 1 {
+		t.Errorf("expected the write to leave the queue, got %+v", stats)
+	}
+}
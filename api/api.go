@@ -3,59 +3,335 @@
 package api
 
 import (
+	"apiGo/features"
 	"apiGo/storage"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
+// ErrAlreadyStarted is returned by Run when the server has already been started.
+var ErrAlreadyStarted = errors.New("server already started")
+
+// ErrShutdownTimeout is returned by Run and Shutdown when in-flight requests don't finish in time.
+var ErrShutdownTimeout = errors.New("in-flight requests didn't finish in time")
+
+// ErrAddressInUse is returned by Run when another process already listens on the server address.
+var ErrAddressInUse = errors.New("address already in use")
+
+// Bounds of the n parameter of randomProducts.
+const (
+	defaultRandomProducts = 5
+	maxRandomProducts     = 50
+)
+
+// lockHolderHeader identifies the editor acquiring or holding a product lock.
+const lockHolderHeader = "X-Lock-Holder"
+
+// maxLockHolderLength is the size of the holder column of product locks.
+const maxLockHolderLength = 100
+
+// Bounds of the limit parameter of getProducts.
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// maxBatchGetIds bounds the number of ids of a single getProductsMap request.
+const maxBatchGetIds = 1000
+
 // Server represents the API server configuration.
 type Server struct {
-	listenAddr string          // Address the server listens on.
-	db         storage.Storage // Database instance.
-	serverMux  *http.ServeMux  // HTTP request multiplexer.
+	listenAddr string              // Address the server listens on.
+	db         storage.Storage     // Database instance.
+	serverMux  *http.ServeMux      // HTTP request multiplexer.
+	config     Config              // Tunable settings.
+	changes    *changeHub          // Recent product changes for push-style endpoints.
+	methods    map[string][]string // Methods registered for each path, advertised on 405 responses.
+	startedAt  time.Time           // When the server was created.
+	requests   atomic.Int64        // Requests served so far.
+	inFlight   *inFlightCounter    // Requests being served, by client IP.
+	views      *viewCounter        // Product views not yet written.
+	admission  *admissionQueue     // Admits requests by priority once saturated. Nil without a limit.
+	streams    atomic.Int64        // Streaming responses in progress.
+
+	httpServer   *http.Server  // Serves serverMux once Run is called.
+	started      atomic.Bool   // Whether Run has been called.
+	stopping     chan struct{} // Closed once a graceful shutdown starts.
+	stoppingOnce sync.Once
+	stopped      chan struct{} // Closed once a graceful shutdown ends.
+	stoppedOnce  sync.Once
 }
 
-// NewApiServer creates a new instance of the API server.
+// NewApiServer creates a new instance of the API server using DefaultConfig.
 func NewApiServer(listenAddr string, storage storage.Storage) *Server {
+	return NewApiServerWithConfig(listenAddr, storage, DefaultConfig())
+}
+
+// NewApiServerWithConfig creates a new instance of the API server with the given configuration.
+func NewApiServerWithConfig(listenAddr string, storage storage.Storage, config Config) *Server {
 	serverMux := http.NewServeMux()
+	var admission *admissionQueue
+	if config.MaxConcurrentRequests > 0 {
+		admission = newAdmissionQueue(config.MaxConcurrentRequests)
+	}
 	return &Server{
 		listenAddr: listenAddr,
 		serverMux:  serverMux,
 		db:         storage,
+		config:     config,
+		changes:    newChangeHub(),
+		methods:    make(map[string][]string),
+		startedAt:  time.Now().UTC(),
+		inFlight:   newInFlightCounter(),
+		views:      newViewCounter(),
+		admission:  admission,
+		// The write timeout bounds the whole request, so a client reading slowly can't hold a handler
+		// forever: once it passes, writes fail and the connection is closed.
+		httpServer: &http.Server{Handler: serverMux, WriteTimeout: config.WriteTimeout},
+		stopping:   make(chan struct{}),
+		stopped:    make(chan struct{}),
 	}
 }
 
 // HandleEndpoints sets up the API endpoints and their corresponding handlers.
 func (o *Server) HandleEndpoints() {
-	o.serverMux.HandleFunc("/getProducts", interceptError(interceptLogger(o.getProducts)))
-	o.serverMux.HandleFunc("/getProduct/{id}", interceptError(interceptLogger(o.getProduct)))
-	o.serverMux.HandleFunc("/createProduct", interceptError(interceptLogger(o.createProduct)))
-	o.serverMux.HandleFunc("/updateProduct/{id}", interceptError(interceptLogger(o.updateProduct)))
+	o.handle("GET /getProducts", o.getProducts, "onlyDuplicates", "status", "limit", "offset", "sort", "idFrom", "idTo", "omitEmpty", "explain")
+	o.handle("GET /getProducts/nameCollisions", o.getNameCollisions)
+	o.handle("GET /searchProducts", o.searchProducts, "q", "limit", "offset")
+	o.handle("GET /countProducts", o.countProducts, "onlyDuplicates", "status", "idFrom", "idTo")
+	o.handle("GET /getProduct/{id}", o.getProduct, "omitEmpty", "links")
+	o.handle("GET /getProductByCode/{code}", o.getProductByCode)
+	o.handle("POST /getProduct/{id}/activate", o.activateProduct)
+	o.handle("POST /getProduct/{id}/deactivate", o.deactivateProduct)
+	o.handle("GET /compareProducts", o.compareProducts, "a", "b")
+	o.handle("POST /getProductsMap", interceptDigest(o.getProductsMap))
+	o.handle("GET /randomProducts", o.getRandomProducts, "n")
+	o.handle("POST /createProduct", interceptDigest(o.createProduct), "ifNotExists")
+	o.handle("POST /validateProducts", interceptDigest(o.validateProducts))
+	o.handle("POST /importProducts", interceptDigest(o.importProducts), "dedupe")
+	o.handle("PUT /updateProduct/{id}", interceptDigest(o.updateProduct))
+	o.handle("PATCH /patchProduct/{id}", interceptDigest(o.partialUpdateProduct))
+	o.handle("DELETE /deleteProduct/{id}", o.deleteProduct)
+	o.handle("POST /mergeProducts", interceptDigest(o.mergeProducts))
+	o.handle("POST /lockProduct/{id}", o.lockProduct)
+	if o.config.Features.Enabled(features.LongPoll) {
+		o.handle("GET /changes/longpoll", o.longPollChanges, "since", "timeout")
+	}
+	o.handle("GET /status", o.getStatus)
+	o.handle("GET /health", o.getHealth)
+	o.handle("GET /admin/config", o.interceptAdminAuth(o.getConfig))
+	o.handle("POST /admin/normalizeCodes", o.interceptAdminAuth(o.normalizeCodes))
+	o.handle("POST /admin/renameSubstring", o.interceptAdminAuth(interceptDigest(o.renameSubstring)))
+
+	for path, methods := range o.methods {
+		o.serverMux.HandleFunc(path, o.interceptCORS(path, o.methodNotAllowed(methods)))
+	}
+}
+
+// handle registers the handler for the given "METHOD /path" pattern behind the common middleware chain.
+// queryParams lists the query parameters the handler understands.
+func (o *Server) handle(pattern string, f apiFunc, queryParams ...string) {
+	method, path, _ := strings.Cut(pattern, " ")
+	o.methods[path] = append(o.methods[path], method)
+	if method == http.MethodGet {
+		o.methods[path] = append(o.methods[path], http.MethodHead)
+	}
+
+	f = o.interceptQuery(queryParams, f)
+	if method != http.MethodGet {
+		f = o.interceptRequestLimit(o.interceptJSONDepth(f))
+	}
+	f = o.interceptAdmission(method, path, f)
+	f = o.interceptClientConcurrency(f)
+	o.serverMux.HandleFunc(pattern, o.interceptRecover(o.interceptCORS(path, o.interceptHTTPSRedirect(o.interceptCount(interceptTrace(path, o.interceptCompression(o.interceptResponseLimit(o.interceptLogger(o.interceptError(f))))))))))
+}
+
+// methodNotAllowed answers requests to a known path with a method it isn't registered for.
+// The method-scoped patterns are more specific, so this only catches the remaining methods.
+func (o *Server) methodNotAllowed(methods []string) http.HandlerFunc {
+	allow := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if err := o.writeError(w, r, newAPIError(http.StatusMethodNotAllowed, "method.notAllowed", r.Method, allow)); err != nil {
+			slog.Error(err.Error())
+		}
+	}
+}
+
+// interceptQuery is a middleware that rejects requests carrying query parameters other than
+// the known ones, when Config.StrictQuery is set. Otherwise unknown parameters are ignored.
+func (o *Server) interceptQuery(known []string, f apiFunc) apiFunc {
+	if !o.config.StrictQuery {
+		return f
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		unknown := make([]string, 0)
+		for name := range r.URL.Query() {
+			if !slices.Contains(known, name) {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) > 0 {
+			slices.Sort(unknown)
+			return newLocalizedError("query.unknown", strings.Join(unknown, ", "))
+		}
+
+		return f(w, r)
+	}
 }
 
-// Run starts the API server.
+// unixAddrPrefix marks a listen address as the path of a Unix domain socket, as in unix:/var/run/api.sock.
+const unixAddrPrefix = "unix:"
+
+// Run starts the API server on its listen address and serves requests until it fails, or until
+// the process receives SIGINT or SIGTERM. In-flight requests then have Config.ShutdownTimeout
+// to finish before Run returns.
 func (o *Server) Run() error {
-	if err := http.ListenAndServe(":8080", o.serverMux); err != nil {
-		slog.Error(err.Error())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return o.RunContext(ctx)
+}
+
+// RunContext is Run stopping gracefully once ctx is done instead of on a signal. A server runs at
+// most once: calling it again returns ErrAlreadyStarted, and after Shutdown it returns right away.
+func (o *Server) RunContext(ctx context.Context) error {
+	if !o.started.CompareAndSwap(false, true) {
+		return ErrAlreadyStarted
+	}
+
+	listener, err := o.listen()
+	if err != nil {
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return fmt.Errorf("%w: %s", ErrAddressInUse, o.listenAddr)
+		}
+		return err
+	}
+
+	// Views are written periodically while serving, and once more after the last request.
+	stopViews := make(chan struct{})
+	go o.flushViewsEvery(o.config.ViewsFlushInterval, stopViews)
+	defer func() {
+		close(stopViews)
+		o.flushViews()
+	}()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- o.httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		// Shutdown was called directly, wait for the requests it lets finish.
+		<-o.stopped
+		return nil
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), o.config.ShutdownTimeout)
+	defer cancel()
+	return o.Shutdown(shutdownCtx)
+}
+
+// Shutdown stops the server gracefully: it stops accepting connections and waits until the in-flight
+// requests finish, or until ctx is done, when they are cut off and ErrShutdownTimeout is returned.
+// It may be called before Run, which then returns right away, and more than once.
+func (o *Server) Shutdown(ctx context.Context) error {
+	defer o.stoppedOnce.Do(func() { close(o.stopped) })
+
+	slog.Info("shutting down, waiting for in-flight requests")
+	o.stoppingOnce.Do(func() { close(o.stopping) })
+	if err := o.httpServer.Shutdown(ctx); err != nil {
+		// Requests still running are cut off when the connections are closed.
+		_ = o.httpServer.Close()
+		return fmt.Errorf("%w: %w", ErrShutdownTimeout, err)
 	}
 
 	return nil
 }
 
+// listen opens the listen address, a TCP address or a Unix socket path prefixed with unix:.
+// A socket file left behind by a previous run is replaced, unless a server still answers on it. The file is removed again when the
+// listener is closed, which Serve does when it returns.
+func (o *Server) listen() (net.Listener, error) {
+	path, found := strings.CutPrefix(o.listenAddr, unixAddrPrefix)
+	if !found {
+		return net.Listen("tcp", o.listenAddr)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("%s: %w", path, syscall.EADDRINUSE)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return net.Listen("unix", path)
+}
+
 type apiFunc func(w http.ResponseWriter, r *http.Request) error
 
-// interceptLogger is a middleware that logs information about API requests.
-func interceptLogger(f apiFunc) apiFunc {
+// interceptLogger is a middleware that logs a line per API request once answered, with its method, path,
+// status and duration, except for the paths of Config.LogExcludedPaths. It wraps interceptError, so
+// the status of error responses is logged too.
+func (o *Server) interceptLogger(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(o.config.LogExcludedPaths, r.URL.Path) {
+			f(w, r)
+			return
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		f(recorder, r)
+
+		slog.Info("service call",
+			"serviceName", getServiceName(r.URL.Path),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"durationMs", float64(time.Since(start).Microseconds())/1000,
+		)
+	}
+}
+
+// interceptAdminAuth is a middleware that only lets requests carrying the admin API key through.
+func (o *Server) interceptAdminAuth(f apiFunc) apiFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		serviceName := getServiceName(r.URL.Path)
-		slog.Info("service call", "serviceName", serviceName)
+		if o.config.AdminAPIKey == "" || !o.config.Features.Enabled(features.Admin) {
+			return newAPIError(http.StatusForbidden, "admin.disabled")
+		}
+
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(o.config.AdminAPIKey)) != 1 {
+			return newAPIError(http.StatusUnauthorized, "admin.unauthorized")
+		}
+
 		return f(w, r)
 	}
 }
@@ -65,18 +341,43 @@ type WebError struct {
 	Error string `json:"error"`
 }
 
-// interceptError is a middleware that intercepts errors and sends appropriate responses to clients.
-func interceptError(f apiFunc) func(http.ResponseWriter, *http.Request) {
+// interceptError is a middleware that intercepts errors and sends appropriate responses to clients,
+// with the status code picked by errorStatus, in the format picked by writeError. Only errors answered
+// with a 5xx print their stack trace, client errors are expected.
+func (o *Server) interceptError(f apiFunc) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		slog.Info("interceptError")
 		if err := f(w, r); err != nil {
-			printStackTrace(err)
-			if err := writeJSON(w, http.StatusBadRequest, WebError{Error: err.Error()}); err != nil {
-				slog.Error("couldn't write")
-				return
+			if status, _ := errorStatus(err); status >= http.StatusInternalServerError {
+				printStackTrace(err)
+			}
+			if err := o.writeError(w, r, err); err != nil {
+				slog.Error("couldn't write", "error", err.Error())
 			}
 		}
-		slog.Info("interceptError after")
+	}
+}
+
+// interceptRecover is a middleware that turns a panicking handler into a 500 response, printing the
+// stack trace, so a bug in one request doesn't take the server down. http.ErrAbortHandler is let
+// through, since the server aborts the response on it silently.
+func (o *Server) interceptRecover(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			printStackTrace(fmt.Errorf("panic serving %s %s: %v", r.Method, r.URL.Path, v))
+			if err := o.writeError(w, r, newAPIError(http.StatusInternalServerError, "service.internal")); err != nil {
+				slog.Error("couldn't write", "error", err.Error())
+			}
+		}()
+
+		f(w, r)
 	}
 }
 
@@ -92,6 +393,16 @@ type getProductResponse struct {
 	Name      string    `json:"name"`
 	Code      string    `json:"code"`
 	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Status    string    `json:"status"`
+	Views     int64     `json:"views"` // Views saved so far, without the most recent ones.
+
+	Links *ProductLinks `json:"_links,omitempty"` // Only with links=true.
+}
+
+// productNotFound is the error answered with 404 when the product with the given ID doesn't exist.
+func productNotFound(id int64) error {
+	return newAPIError(http.StatusNotFound, "product.notFound", id)
 }
 
 // getProduct retrieves a product by its ID.
@@ -101,7 +412,57 @@ func (o *Server) getProduct(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	p, err := o.db.GetProductById(id)
+	omitEmpty, err := getBoolParam(r, "omitEmpty")
+	if err != nil {
+		return err
+	}
+	withLinks, err := getBoolParam(r, "links")
+	if err != nil {
+		return err
+	}
+
+	p, err := o.db.GetProductById(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return productNotFound(id)
+	}
+	if err != nil {
+		return err
+	}
+	o.views.record(id)
+
+	var links *ProductLinks
+	if withLinks {
+		links = o.productLinks(id)
+	}
+	if omitEmpty {
+		compact := compactProduct(p)
+		compact.Links = links
+		return writeJSON(w, http.StatusOK, compact)
+	}
+
+	response := getProductResponse{
+		Id:        p.Id,
+		Name:      p.Name,
+		Code:      p.Code,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		Status:    p.Status,
+		Views:     p.Views,
+		Links:     links,
+	}
+
+	return writeJSON(w, http.StatusOK, response)
+}
+
+// getProductByCode retrieves a product by its code. The code is a single path segment, so codes
+// containing a slash, or other reserved characters, must be percent-encoded; they arrive decoded.
+func (o *Server) getProductByCode(w http.ResponseWriter, r *http.Request) error {
+	code := r.PathValue("code")
+
+	p, err := o.db.GetProductByCode(r.Context(), code)
+	if errors.Is(err, storage.ErrNotFound) {
+		return newAPIError(http.StatusNotFound, "product.codeNotFound", code)
+	}
 	if err != nil {
 		return err
 	}
@@ -111,6 +472,9 @@ func (o *Server) getProduct(w http.ResponseWriter, r *http.Request) error {
 		Name:      p.Name,
 		Code:      p.Code,
 		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		Status:    p.Status,
+		Views:     p.Views,
 	}
 
 	return writeJSON(w, http.StatusOK, response)
@@ -118,8 +482,22 @@ func (o *Server) getProduct(w http.ResponseWriter, r *http.Request) error {
 
 // CreateProductRequest represents the request structure for createProduct API.
 type CreateProductRequest struct {
-	Name string `json:"name"`
-	Code string `json:"code"`
+	Name      string     `json:"name"`
+	Code      string     `json:"code"`
+	Status    string     `json:"status"`    // Defaults to active.
+	CreatedAt *time.Time `json:"createdAt"` // Defaults to now, for imports of products created elsewhere.
+}
+
+// newProduct builds the product to be created from the request.
+func (o *CreateProductRequest) newProduct() *storage.Product {
+	p := storage.NewProduct(o.Name, o.Code)
+	if o.Status != "" {
+		p.Status = o.Status
+	}
+	if o.CreatedAt != nil {
+		p.CreatedAt = o.CreatedAt.UTC()
+	}
+	return p
 }
 
 // CreateProductResponse represents the response structure for createProduct API.
@@ -128,68 +506,594 @@ type CreateProductResponse struct {
 	Name      string    `json:"name"`
 	Code      string    `json:"code"`
 	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Status    string    `json:"status"`
+	Warnings  []string  `json:"warnings,omitempty"`
+}
+
+// CreateProductAcceptedResponse represents the response of createProduct when the write is queued asynchronously.
+type CreateProductAcceptedResponse struct {
+	Id        int64     `json:"id"` // Reserved when queued, so the product can be read once written.
+	Name      string    `json:"name"`
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"createdAt"`
+	Status    string    `json:"status"`
+	Warnings  []string  `json:"warnings,omitempty"`
+}
+
+// productQueue is implemented by storages able to persist products asynchronously.
+type productQueue interface {
+	EnqueueProduct(ctx context.Context, p *storage.Product, flushed func(*storage.Product)) error
 }
 
-// createProduct creates a new product.
+// createProduct creates a new product. With If-None-Match: * or ifNotExists=true, the product is only
+// created when no product has the same code, and 409 is returned otherwise.
 func (o *Server) createProduct(w http.ResponseWriter, r *http.Request) error {
-	request := new(CreateProductRequest)
-	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+	ifNotExists, err := getBoolParam(r, "ifNotExists")
+	if err != nil {
 		return err
 	}
+	ifNotExists = ifNotExists || r.Header.Get("If-None-Match") == "*"
 
-	p := storage.NewProduct(request.Name, request.Code)
+	request := new(CreateProductRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		return decodeError(err)
+	}
 
-	product, err := o.db.CreateProduct(p)
+	warnings, err := o.validateCreateRequest(r, request)
 	if err != nil {
 		return err
 	}
 
-	response := CreateProductResponse{
-		Id:        product.Id,
-		Name:      product.Name,
-		Code:      product.Code,
-		CreatedAt: product.CreatedAt,
+	p := request.newProduct()
+
+	if queue, ok := o.db.(productQueue); ok && o.config.AsyncCreate && !ifNotExists {
+		// Published once written, as the products created synchronously are.
+		if err := queue.EnqueueProduct(r.Context(), p, o.changes.publish); err != nil {
+			return err
+		}
+
+		response := CreateProductAcceptedResponse{
+			Id:        p.Id,
+			Name:      p.Name,
+			Code:      p.Code,
+			CreatedAt: p.CreatedAt,
+			Status:    p.Status,
+			Warnings:  warnings,
+		}
+
+		return writeJSON(w, http.StatusAccepted, response)
 	}
 
-	return writeJSON(w, http.StatusOK, response)
+	var product *storage.Product
+	if ifNotExists {
+		product, err = o.db.CreateProductIfCodeAbsent(r.Context(), p)
+	} else {
+		product, err = o.db.CreateProduct(r.Context(), p)
+	}
+	switch {
+	case errors.Is(err, storage.ErrCodeExists), errors.Is(err, storage.ErrDuplicateCode):
+		return newAPIError(http.StatusConflict, "product.codeExists", p.Code)
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return newAPIError(http.StatusForbidden, "product.quotaExceeded")
+	case err != nil:
+		return err
+	}
+	o.changes.publish(product)
+
+	return writeJSON(w, http.StatusOK, newCreateProductResponse(product, warnings))
+}
+
+// newCreateProductResponse builds the createProduct response of a persisted product.
+func newCreateProductResponse(p *storage.Product, warnings []string) CreateProductResponse {
+	return CreateProductResponse{
+		Id:        p.Id,
+		Name:      p.Name,
+		Code:      p.Code,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		Status:    p.Status,
+		Warnings:  warnings,
+	}
 }
 
 // UpdateProductRequest represents the request structure for updateProduct API.
 type UpdateProductRequest struct {
-	Id   int64  `json:"id"`
-	Name string `json:"name"`
-	Code string `json:"code"`
+	Id     int64  `json:"id"` // Optional, must match the id of the path when given.
+	Name   string `json:"name"`
+	Code   string `json:"code"`
+	Status string `json:"status"` // Empty keeps the current status.
+
+	updatedAt time.Time // Set by patchProduct to the modification time it read, so concurrent changes fail the update.
 }
 
-// updateProduct updates an existing product.
+// UpdateProductResponse represents the response structure for updateProduct API.
+type UpdateProductResponse struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Status    string    `json:"status"`
+	Warnings  []string  `json:"warnings,omitempty"`
+}
+
+// updateProduct updates an existing product. Bodies sent as application/json-patch+json are applied as a JSON Patch.
 func (o *Server) updateProduct(w http.ResponseWriter, r *http.Request) error {
+	if isJSONPatch(r) {
+		return o.patchProduct(w, r)
+	}
+
+	id, err := getId(r)
+	if err != nil {
+		return err
+	}
+
 	request := new(UpdateProductRequest)
 	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		return decodeError(err)
+	}
+	// The id of the body is optional, the one of the path identifies the product.
+	if request.Id != 0 && request.Id != id {
+		return newLocalizedError("id.mismatch", request.Id, id)
+	}
+	request.Id = id
+
+	exists, err := o.db.ProductExists(r.Context(), request.Id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return productNotFound(request.Id)
+	}
+
+	return o.saveProduct(w, r, request)
+}
+
+// saveProduct persists the update of an existing product, unless another editor holds its lock.
+func (o *Server) saveProduct(w http.ResponseWriter, r *http.Request, request *UpdateProductRequest) error {
+	if err := o.sanitizeProduct(&request.Name, &request.Code); err != nil {
+		return err
+	}
+
+	// Validated once sanitized, so stripped control characters can't leave a blank name behind.
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	if err := o.checkCode(request.Code); err != nil {
+		return err
+	}
+
+	if err := o.checkLengths(request.Name, request.Code); err != nil {
+		return err
+	}
+
+	warnings, err := o.checkWarnings(r, productWarnings(request.Code))
+	if err != nil {
+		return err
+	}
+
+	if err := o.checkLock(r, request.Id); err != nil {
 		return err
 	}
 
 	p := &storage.Product{
-		Id:   request.Id,
-		Name: request.Name,
-		Code: request.Code,
+		Id:        request.Id,
+		Name:      request.Name,
+		Code:      request.Code,
+		Status:    request.Status,
+		UpdatedAt: request.updatedAt,
+	}
+
+	updatedProduct, err := o.db.UpdateProduct(r.Context(), p)
+	if err != nil {
+		return err
+	}
+	o.changes.publish(updatedProduct)
+
+	response := UpdateProductResponse{
+		Id:        updatedProduct.Id,
+		Name:      updatedProduct.Name,
+		Code:      updatedProduct.Code,
+		CreatedAt: updatedProduct.CreatedAt,
+		UpdatedAt: updatedProduct.UpdatedAt,
+		Status:    updatedProduct.Status,
+		Warnings:  warnings,
+	}
+
+	return writeJSON(w, http.StatusOK, response)
+}
+
+// checkLock returns an error answered with 423 when another editor holds the lock of the product.
+func (o *Server) checkLock(r *http.Request, id int64) error {
+	lock, err := o.db.GetProductLock(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	if lock != nil && lock.Holder != r.Header.Get(lockHolderHeader) {
+		return newAPIError(http.StatusLocked, "product.locked", lock.ProductId, lock.Holder, lock.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// lockProduct locks a product for the editor named in the X-Lock-Holder header.
+// Other editors can't update the product until the lock expires.
+func (o *Server) lockProduct(w http.ResponseWriter, r *http.Request) error {
+	id, err := getId(r)
+	if err != nil {
+		return err
+	}
+
+	holder := r.Header.Get(lockHolderHeader)
+	if holder == "" || len(holder) > maxLockHolderLength {
+		return newLocalizedError("lock.holderMissing", maxLockHolderLength)
+	}
+
+	exists, err := o.db.ProductExists(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return productNotFound(id)
+	}
+
+	lock, err := o.db.LockProduct(r.Context(), id, holder, o.config.LockTTL)
+	if errors.Is(err, storage.ErrProductLocked) {
+		current, lockErr := o.db.GetProductLock(r.Context(), id)
+		if lockErr != nil {
+			return lockErr
+		}
+		if current == nil {
+			// The other lock expired in between, the client may try again right away.
+			return newAPIError(http.StatusLocked, "product.lockExpired")
+		}
+		return newAPIError(http.StatusLocked, "product.locked", current.ProductId, current.Holder, current.ExpiresAt.Format(time.RFC3339))
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, lock)
+}
+
+// DeleteProductResponse represents the response structure for deleteProduct API.
+type DeleteProductResponse struct {
+	Deleted bool `json:"deleted"`
+}
+
+// deleteProduct removes a product by its ID, unless another editor holds its lock.
+func (o *Server) deleteProduct(w http.ResponseWriter, r *http.Request) error {
+	id, err := getId(r)
+	if err != nil {
+		return err
+	}
+
+	if err := o.checkLock(r, id); err != nil {
+		return err
+	}
+
+	err = o.db.DeleteProduct(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return productNotFound(id)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, DeleteProductResponse{Deleted: true})
+}
+
+// MergeProductsRequest represents the request structure for mergeProducts API.
+type MergeProductsRequest struct {
+	KeepId  int64 `json:"keepId"`
+	MergeId int64 `json:"mergeId"` // Deleted once merged.
+}
+
+// MergeProductsResponse represents the response structure for mergeProducts API.
+type MergeProductsResponse struct {
+	Product  *storage.Product `json:"product"` // The kept product, as stored after the merge.
+	MergedId int64            `json:"mergedId"`
+}
+
+// mergeProducts merges a duplicate product into the one kept, which gets its views, then deletes the
+// duplicate. Neither may be locked by another editor.
+func (o *Server) mergeProducts(w http.ResponseWriter, r *http.Request) error {
+	request := new(MergeProductsRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		return decodeError(err)
+	}
+	if request.KeepId == request.MergeId {
+		return newLocalizedError("merge.sameProduct", request.KeepId)
+	}
+
+	for _, id := range []int64{request.KeepId, request.MergeId} {
+		if err := o.checkLock(r, id); err != nil {
+			return err
+		}
 	}
 
-	updatedProduct, err := o.db.UpdateProduct(p)
+	kept, err := o.db.MergeProducts(r.Context(), request.KeepId, request.MergeId)
+	if errors.Is(err, storage.ErrNotFound) {
+		return newAPIError(http.StatusNotFound, "merge.notFound", request.KeepId, request.MergeId)
+	}
 	if err != nil {
 		return err
 	}
+	o.changes.publish(kept)
 
-	return writeJSON(w, http.StatusOK, updatedProduct)
+	return writeJSON(w, http.StatusOK, MergeProductsResponse{Product: kept, MergedId: request.MergeId})
 }
 
-// GetProductsResponse represents the response structure for getProducts API.
+// GetProductsResponse represents the response structure of the product listing APIs.
 type GetProductsResponse struct {
 	Products []*storage.Product `json:"products"`
 }
 
-// getProducts retrieves all products.
-func (o *Server) getProducts(w http.ResponseWriter, _ *http.Request) error {
-	products, err := o.db.GetProducts()
+// GetProductsPageResponse represents the response structure for getProducts API, as written by writeProductsPage.
+type GetProductsPageResponse struct {
+	Products []*storage.Product `json:"products"`
+	Total    int64              `json:"total"` // Products matching the filters across all pages.
+	Limit    int                `json:"limit"`
+	Offset   int                `json:"offset"`
+}
+
+// getProducts retrieves a page of the products, optionally filtered by status or to those sharing their
+// code with another product or within an ID range, and sorted by ID or most viewed first.
+func (o *Server) getProducts(w http.ResponseWriter, r *http.Request) error {
+	filter, err := getProductFilter(r)
+	if err != nil {
+		return err
+	}
+
+	page, err := getPage(r)
+	if err != nil {
+		return err
+	}
+	page.Sort = r.URL.Query().Get("sort")
+	if !storage.ValidSort(page.Sort) {
+		return newLocalizedError("query.invalidSort", page.Sort)
+	}
+
+	explain, err := getBoolParam(r, "explain")
+	if err != nil {
+		return err
+	}
+	omitEmpty, err := getBoolParam(r, "omitEmpty")
+	if err != nil {
+		return err
+	}
+	if explain {
+		return o.interceptAdminAuth(func(w http.ResponseWriter, _ *http.Request) error {
+			return explainProducts(w, filter, page)
+		})(w, r)
+	}
+
+	products, total, err := o.db.GetProducts(r.Context(), filter, page)
+	if err != nil {
+		return err
+	}
+	if total == 0 && o.config.EmptyAs404 && filter != (storage.ProductFilter{}) {
+		return newAPIError(http.StatusNotFound, "products.noneMatch")
+	}
+
+	if !o.config.Features.Enabled(features.Streaming) {
+		return bufferProductsPage(w, products, total, page, omitEmpty)
+	}
+	return writeProductsPage(w, products, total, page, omitEmpty)
+}
+
+// getProductFilter reads the onlyDuplicates, status, idFrom and idTo query parameters filtering listings.
+func getProductFilter(r *http.Request) (storage.ProductFilter, error) {
+	var (
+		filter storage.ProductFilter
+		err    error
+	)
+
+	if filter.OnlyDuplicates, err = getBoolParam(r, "onlyDuplicates"); err != nil {
+		return filter, err
+	}
+	filter.Status = r.URL.Query().Get("status")
+	if err := checkStatus(filter.Status); err != nil {
+		return filter, err
+	}
+	if filter.IdFrom, filter.IdTo, err = getIdRange(r); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// getPage reads the limit and offset query parameters paging listings.
+func getPage(r *http.Request) (storage.Page, error) {
+	var (
+		page storage.Page
+		err  error
+	)
+
+	if page.Limit, err = getIntParam(r, "limit", defaultPageLimit, 1, maxPageLimit); err != nil {
+		return page, err
+	}
+	if page.Offset, err = getIntParam(r, "offset", 0, 0, math.MaxInt32); err != nil {
+		return page, err
+	}
+
+	return page, nil
+}
+
+// SearchProductsResponse represents the response structure for searchProducts API.
+type SearchProductsResponse struct {
+	Products []*storage.Product `json:"products"` // Empty, not null, when nothing matches.
+	Limit    int                `json:"limit"`
+	Offset   int                `json:"offset"`
+}
+
+// searchProducts retrieves a page of the products whose name contains q, ignoring case.
+func (o *Server) searchProducts(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		return newLocalizedError("validation.required", "q")
+	}
+
+	page, err := getPage(r)
+	if err != nil {
+		return err
+	}
+
+	products, err := o.db.SearchProducts(r.Context(), query, page)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, SearchProductsResponse{Products: products, Limit: page.Limit, Offset: page.Offset})
+}
+
+// CountProductsResponse represents the response structure for countProducts API.
+type CountProductsResponse struct {
+	Total int64 `json:"total"`
+}
+
+// countProducts answers with the number of products matching the filters of getProducts, without listing them.
+func (o *Server) countProducts(w http.ResponseWriter, r *http.Request) error {
+	filter, err := getProductFilter(r)
+	if err != nil {
+		return err
+	}
+
+	total, err := o.db.CountProducts(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, CountProductsResponse{Total: total})
+}
+
+// ExplainProductsResponse represents the response structure for getProducts API with explain=true.
+type ExplainProductsResponse struct {
+	Query string `json:"query"`
+	Args  []any  `json:"args"`
+}
+
+// explainProducts answers with the SQL and arguments the listing would run, without running it.
+func explainProducts(w http.ResponseWriter, filter storage.ProductFilter, page storage.Page) error {
+	query, args := storage.BuildProductsQuery(filter, page)
+	return writeJSON(w, http.StatusOK, ExplainProductsResponse{Query: query, Args: args})
+}
+
+// getConfig returns the effective server configuration with secrets redacted.
+func (o *Server) getConfig(w http.ResponseWriter, _ *http.Request) error {
+	return writeJSON(w, http.StatusOK, o.config.Redacted())
+}
+
+// normalizeCodes trims and uppercases the codes of all products, reporting the ones left out by a collision.
+// Each product changed is published to long polls, like any other write.
+func (o *Server) normalizeCodes(w http.ResponseWriter, r *http.Request) error {
+	result, err := o.db.NormalizeCodes(r.Context())
+	if err != nil {
+		return err
+	}
+	for _, p := range result.Normalized {
+		o.changes.publish(p)
+	}
+
+	return writeJSON(w, http.StatusOK, result)
+}
+
+// RenameSubstringRequest represents the request structure for renameSubstring API.
+type RenameSubstringRequest struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// RenameSubstringResponse represents the response structure for renameSubstring API.
+type RenameSubstringResponse struct {
+	Affected int64 `json:"affected"`
+}
+
+// renameSubstring replaces a substring in the name or code of every product, for bulk corrections.
+// Each product changed is published to long polls, like any other write.
+func (o *Server) renameSubstring(w http.ResponseWriter, r *http.Request) error {
+	request := new(RenameSubstringRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		return decodeError(err)
+	}
+	if !slices.Contains(storage.ReplaceableFields, request.Field) {
+		return newLocalizedError("rename.fieldNotAllowed", request.Field, strings.Join(storage.ReplaceableFields, ", "))
+	}
+	if request.From == "" {
+		return newLocalizedError("validation.required", "from")
+	}
+	if err := o.sanitizeField(request.Field, &request.To); err != nil {
+		return err
+	}
+
+	replaced, err := o.db.ReplaceSubstring(r.Context(), request.Field, request.From, request.To)
+	if err != nil {
+		return err
+	}
+	for _, p := range replaced {
+		o.changes.publish(p)
+	}
+
+	return writeJSON(w, http.StatusOK, RenameSubstringResponse{Affected: int64(len(replaced))})
+}
+
+// GetProductsMapRequest represents the request structure for getProductsMap API.
+type GetProductsMapRequest struct {
+	Ids []int64 `json:"ids"`
+}
+
+// GetProductsMapResponse represents the response structure for getProductsMap API.
+// Products are keyed by their ID as a string, so large IDs survive JavaScript number precision.
+type GetProductsMapResponse map[string]*storage.Product
+
+// getProductsMap retrieves several products by ID, keyed by ID. Missing IDs are omitted.
+func (o *Server) getProductsMap(w http.ResponseWriter, r *http.Request) error {
+	request := new(GetProductsMapRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		return decodeError(err)
+	}
+	if len(request.Ids) > maxBatchGetIds {
+		return newLocalizedError("ids.tooMany", maxBatchGetIds, len(request.Ids))
+	}
+
+	products, err := o.db.GetProductsByIds(r.Context(), request.Ids)
+	if err != nil {
+		return err
+	}
+
+	response := make(GetProductsMapResponse, len(products))
+	for _, p := range products {
+		response[strconv.FormatInt(p.Id, 10)] = p
+	}
+
+	return writeJSON(w, http.StatusOK, response)
+}
+
+// GetNameCollisionsResponse represents the response structure for getNameCollisions API.
+type GetNameCollisionsResponse struct {
+	Collisions []*storage.NameCollision `json:"collisions"`
+}
+
+// getNameCollisions retrieves the groups of products sharing a name with different codes.
+func (o *Server) getNameCollisions(w http.ResponseWriter, r *http.Request) error {
+	collisions, err := o.db.GetNameCollisions(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, GetNameCollisionsResponse{Collisions: collisions})
+}
+
+// getRandomProducts retrieves n products picked at random, or fewer when there aren't that many.
+func (o *Server) getRandomProducts(w http.ResponseWriter, r *http.Request) error {
+	n, err := getIntParam(r, "n", defaultRandomProducts, 1, maxRandomProducts)
+	if err != nil {
+		return err
+	}
+
+	products, err := o.db.GetRandomProducts(r.Context(), n)
 	if err != nil {
 		return err
 	}
@@ -205,16 +1109,59 @@ func getId(r *http.Request) (int64, error) {
 	path := r.URL.Path
 	parts := strings.Split(path, "/")
 	if len(parts) < 3 {
-		return 0, errors.New("the id argument is not present")
+		return 0, newLocalizedError("id.missing")
 	}
 	id := parts[2]
 	n, err := strconv.Atoi(id)
 	if err != nil {
-		return 0, fmt.Errorf("numeric id is expected. Given: %s", id)
+		return 0, newLocalizedError("id.notNumeric", id)
 	}
 	return int64(n), nil
 }
 
+// getBoolParam parses the named query parameter as a boolean. A missing parameter is false.
+func getBoolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, newLocalizedError("query.invalidBool", name, value)
+	}
+	return b, nil
+}
+
+// getIntParam parses the named query parameter as an integer within [minValue, maxValue].
+// A missing parameter takes the fallback value.
+func getIntParam(r *http.Request, name string, fallback, minValue, maxValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < minValue || n > maxValue {
+		return 0, newLocalizedError("query.intRange", name, minValue, maxValue, value)
+	}
+	return n, nil
+}
+
+// getIdRange parses the optional idFrom and idTo bounds of a listing, zero when absent.
+func getIdRange(r *http.Request) (int64, int64, error) {
+	from, err := getIntParam(r, "idFrom", 0, 1, math.MaxInt)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := getIntParam(r, "idTo", 0, 1, math.MaxInt)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > 0 && to > 0 && from > to {
+		return 0, 0, newLocalizedError("query.invalidIdRange", from, to)
+	}
+	return int64(from), int64(to), nil
+}
+
 // getServiceName extracts the service name from the request URL.
 func getServiceName(path string) string {
 	parts := strings.Split(path, "/")
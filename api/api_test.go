@@ -0,0 +1,553 @@
+package api
+
+import (
+	"apiGo/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer creates a Server over a MemStorage holding products, with its endpoints registered.
+func newTestServer(t *testing.T, config Config, products ...*storage.Product) (*Server, *storage.MemStorage) {
+	t.Helper()
+
+	db := storage.NewMemStorage()
+	for _, p := range products {
+		if _, err := db.CreateProduct(context.Background(), p); err != nil {
+			t.Fatalf("product %q couldn't be created: %v", p.Code, err)
+		}
+	}
+
+	server := NewApiServerWithConfig(":0", db, config)
+	server.HandleEndpoints()
+	return server, db
+}
+
+// serve runs a request against the server, with a JSON body unless body is empty.
+func serve(server *Server, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	server.serverMux.ServeHTTP(w, r)
+	return w
+}
+
+// captureLogs makes the default logger write JSON records to the returned buffer until the test ends.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	logs := new(bytes.Buffer)
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return logs
+}
+
+// decode decodes the JSON body of a response into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("body %q isn't valid JSON: %v", w.Body.String(), err)
+	}
+}
+
+// expectStatus fails the test unless the response has the status.
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+}
+
+func TestGetProductNotFound(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodGet, "/getProduct/9", "")
+	expectStatus(t, w, http.StatusNotFound)
+
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "product with ID 9 not found" {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodPost, "/getProduct/1", "")
+	expectStatus(t, w, http.StatusMethodNotAllowed)
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+		t.Errorf("unexpected Allow header: %q", allow)
+	}
+}
+
+func TestUpdateProductRejectsAnotherIdInTheBody(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodPut, "/updateProduct/1", `{"id": 77, "name": "Table", "code": "TBL-1"}`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	w = serve(server, http.MethodPut, "/updateProduct/1", `{"name": "Table", "code": "TBL-1"}`)
+	expectStatus(t, w, http.StatusOK)
+	var body UpdateProductResponse
+	decode(t, w, &body)
+	if body.Id != 1 || body.Name != "Table" {
+		t.Errorf("unexpected product: %+v", body)
+	}
+}
+
+func TestUpdateOfLockedProductAnswersLocked(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodPost, "/lockProduct/1", "", lockHolderHeader, "alice")
+	expectStatus(t, w, http.StatusOK)
+
+	w = serve(server, http.MethodPut, "/updateProduct/1", `{"name": "Table", "code": "TBL-1"}`, lockHolderHeader, "bob")
+	expectStatus(t, w, http.StatusLocked)
+
+	w = serve(server, http.MethodPut, "/updateProduct/1", `{"name": "Table", "code": "TBL-1"}`, lockHolderHeader, "alice")
+	expectStatus(t, w, http.StatusOK)
+}
+
+func TestMergeProducts(t *testing.T) {
+	server, db := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"), storage.NewProduct("Desk", "DSK-2"))
+	if err := db.AddProductViews(context.Background(), map[int64]int64{1: 3, 2: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(server, http.MethodPost, "/mergeProducts", `{"keepId": 1, "mergeId": 2}`)
+	expectStatus(t, w, http.StatusOK)
+
+	var body MergeProductsResponse
+	decode(t, w, &body)
+	if body.Product.Id != 1 || body.Product.Views != 7 || body.MergedId != 2 {
+		t.Errorf("unexpected merge: %+v %+v", body, body.Product)
+	}
+
+	expectStatus(t, serve(server, http.MethodGet, "/getProduct/2", ""), http.StatusNotFound)
+	expectStatus(t, serve(server, http.MethodPost, "/mergeProducts", `{"keepId": 1, "mergeId": 2}`), http.StatusNotFound)
+	expectStatus(t, serve(server, http.MethodPost, "/mergeProducts", `{"keepId": 1, "mergeId": 1}`), http.StatusBadRequest)
+}
+
+func TestAsyncCreateAnswersTheReservedId(t *testing.T) {
+	config := DefaultConfig()
+	config.AsyncCreate = true
+	db := storage.NewBatchStorage(storage.NewMemStorage(), storage.BatchConfig{Enabled: true, Size: 10, FlushInterval: 10 * time.Millisecond, DrainTimeout: time.Second})
+	defer db.Close()
+	server := NewApiServerWithConfig(":0", db, config)
+	server.HandleEndpoints()
+	since := time.Now().UTC()
+
+	w := serve(server, http.MethodPost, "/createProduct", `{"name": "Desk", "code": "DSK-1"}`)
+	expectStatus(t, w, http.StatusAccepted)
+	var body CreateProductAcceptedResponse
+	decode(t, w, &body)
+	if body.Id != 1 {
+		t.Fatalf("expected the reserved id 1, got %+v", body)
+	}
+
+	// The long poll is woken up once the batch is written.
+	w = serve(server, http.MethodGet, "/changes/longpoll?timeout=5s&since="+url.QueryEscape(since.Format(time.RFC3339Nano)), "")
+	expectStatus(t, w, http.StatusOK)
+	var changes LongPollChangesResponse
+	decode(t, w, &changes)
+	if len(changes.Products) != 1 || changes.Products[0].Id != body.Id {
+		t.Fatalf("expected the change of product %d, got %+v", body.Id, changes.Products)
+	}
+
+	expectStatus(t, serve(server, http.MethodGet, "/getProduct/1", ""), http.StatusOK)
+}
+
+func TestPanickingHandlerAnswersInternalErrorAndTheServerKeepsServing(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+	server.handle("GET /panic", func(http.ResponseWriter, *http.Request) error {
+		panic("boom")
+	})
+
+	w := serve(server, http.MethodGet, "/panic", "", "Accept", problemContentType)
+	expectStatus(t, w, http.StatusInternalServerError)
+	var problem Problem
+	decode(t, w, &problem)
+	if problem.Status != http.StatusInternalServerError || problem.Instance != "/panic" {
+		t.Errorf("unexpected problem: %+v", problem)
+	}
+
+	expectStatus(t, serve(server, http.MethodGet, "/getProduct/1", ""), http.StatusOK)
+}
+
+func TestGetProductsMapKeysProductsByIdAndOmitsMissingOnes(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"), storage.NewProduct("Chair", "CHR-1"))
+
+	w := serve(server, http.MethodPost, "/getProductsMap", `{"ids":[1,2,9]}`)
+	expectStatus(t, w, http.StatusOK)
+
+	var body map[string]storage.Product
+	decode(t, w, &body)
+	if len(body) != 2 {
+		t.Fatalf("expected products 1 and 2, got %+v", body)
+	}
+	if body["1"].Code != "DSK-1" || body["2"].Code != "CHR-1" {
+		t.Errorf("expected each product under its id, got %+v", body)
+	}
+	if _, found := body["9"]; found {
+		t.Error("expected the missing id to be absent")
+	}
+}
+
+func TestRandomProductsReturnsTheRequestedCountOrFewer(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"), storage.NewProduct("Chair", "CHR-1"), storage.NewProduct("Lamp", "LMP-1"))
+
+	for n, count := range map[string]int{"2": 2, "5": 3} {
+		w := serve(server, http.MethodGet, "/randomProducts?n="+n, "")
+		expectStatus(t, w, http.StatusOK)
+
+		var body GetProductsResponse
+		decode(t, w, &body)
+		if len(body.Products) != count {
+			t.Errorf("expected %d products for n=%s, got %d", count, n, len(body.Products))
+		}
+	}
+
+	for _, n := range []string{"0", "51"} {
+		expectStatus(t, serve(server, http.MethodGet, "/randomProducts?n="+n, ""), http.StatusBadRequest)
+	}
+}
+
+func TestConditionalCreateOnlyCreatesAbsentCodes(t *testing.T) {
+	conditions := []struct {
+		name, target string
+		headers      []string
+	}{
+		{"ifNotExists", "/createProduct?ifNotExists=true", nil},
+		{"If-None-Match", "/createProduct", []string{"If-None-Match", "*"}},
+	}
+
+	for _, condition := range conditions {
+		t.Run(condition.name, func(t *testing.T) {
+			server, db := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+			w := serve(server, http.MethodPost, condition.target, `{"name":"Chair","code":"CHR-1"}`, condition.headers...)
+			expectStatus(t, w, http.StatusOK)
+
+			w = serve(server, http.MethodPost, condition.target, `{"name":"Table","code":"DSK-1"}`, condition.headers...)
+			expectStatus(t, w, http.StatusConflict)
+
+			var body WebError
+			decode(t, w, &body)
+			if body.Error != `a product with code "DSK-1" already exists` {
+				t.Errorf("unexpected error: %q", body.Error)
+			}
+			product, err := db.GetProductById(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if product.Name != "Desk" {
+				t.Errorf("expected the existing product to be left as is, got %q", product.Name)
+			}
+		})
+	}
+}
+
+// quotaStorage is a MemStorage refusing to hold more than max products, as PgStorage does with PRODUCT_QUOTA.
+type quotaStorage struct {
+	*storage.MemStorage
+	max int64
+}
+
+func (o *quotaStorage) CreateProduct(ctx context.Context, p *storage.Product) (*storage.Product, error) {
+	count, err := o.CountProducts(ctx, storage.ProductFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if count >= o.max {
+		return nil, storage.ErrQuotaExceeded
+	}
+	return o.MemStorage.CreateProduct(ctx, p)
+}
+
+func TestCreateProductBeyondTheQuotaAnswersForbidden(t *testing.T) {
+	server := NewApiServerWithConfig(":0", &quotaStorage{MemStorage: storage.NewMemStorage(), max: 2}, DefaultConfig())
+	server.HandleEndpoints()
+
+	for _, code := range []string{"DSK-1", "CHR-1"} {
+		expectStatus(t, serve(server, http.MethodPost, "/createProduct", `{"name":"Desk","code":"`+code+`"}`), http.StatusOK)
+	}
+
+	w := serve(server, http.MethodPost, "/createProduct", `{"name":"Lamp","code":"LMP-1"}`)
+	expectStatus(t, w, http.StatusForbidden)
+
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "the product quota has been reached, no more products can be created" {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+}
+
+func TestNameCollisionsGroupsProductsSharingANameAcrossCodes(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(),
+		storage.NewProduct("Desk", "DSK-1"), storage.NewProduct("Chair", "CHR-1"), storage.NewProduct("Desk", "DSK-2"),
+		storage.NewProduct("Lamp", "LMP-1"), storage.NewProduct("Chair", "CHR-2"))
+
+	w := serve(server, http.MethodGet, "/getProducts/nameCollisions", "")
+	expectStatus(t, w, http.StatusOK)
+
+	var body GetNameCollisionsResponse
+	decode(t, w, &body)
+	groups := make(map[string][]int64)
+	for _, collision := range body.Collisions {
+		for _, p := range collision.Products {
+			groups[collision.Name] = append(groups[collision.Name], p.Id)
+		}
+	}
+	if len(groups) != 2 || !slices.Equal(groups["Desk"], []int64{1, 3}) || !slices.Equal(groups["Chair"], []int64{2, 5}) {
+		t.Errorf("expected the Desk and Chair groups, got %v", groups)
+	}
+}
+
+func TestStrictQueryRejectsUnknownParameters(t *testing.T) {
+	config := DefaultConfig()
+	config.StrictQuery = true
+	server, _ := newTestServer(t, config, storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodGet, "/getProducts?limits=10&sort=name&page=2", "")
+	expectStatus(t, w, http.StatusBadRequest)
+
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "unknown query parameters: limits, page" {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+
+	expectStatus(t, serve(server, http.MethodGet, "/getProducts?limit=10&sort=name", ""), http.StatusOK)
+}
+
+func TestLenientQueryIgnoresUnknownParameters(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodGet, "/getProducts?limits=10", "")
+	expectStatus(t, w, http.StatusOK)
+
+	var body GetProductsResponse
+	decode(t, w, &body)
+	if len(body.Products) != 1 {
+		t.Errorf("expected the listing, got %+v", body.Products)
+	}
+}
+
+func TestDeleteProduct(t *testing.T) {
+	server, db := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodDelete, "/deleteProduct/1", "")
+	expectStatus(t, w, http.StatusOK)
+
+	var body DeleteProductResponse
+	decode(t, w, &body)
+	if !body.Deleted {
+		t.Error("expected deleted to be true")
+	}
+	if _, err := db.GetProductById(context.Background(), 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the product to be gone, got %v", err)
+	}
+
+	w = serve(server, http.MethodDelete, "/deleteProduct/1", "")
+	expectStatus(t, w, http.StatusNotFound)
+}
+
+func TestEmptyFilteredListings(t *testing.T) {
+	for _, emptyAs404 := range []bool{false, true} {
+		config := DefaultConfig()
+		config.EmptyAs404 = emptyAs404
+		server, _ := newTestServer(t, config, storage.NewProduct("Desk", "DSK-1"))
+
+		w := serve(server, http.MethodGet, "/getProducts?status=draft", "")
+		if emptyAs404 {
+			expectStatus(t, w, http.StatusNotFound)
+			var body WebError
+			decode(t, w, &body)
+			if body.Error != "no product matches the filters" {
+				t.Errorf("unexpected error: %q", body.Error)
+			}
+		} else {
+			expectStatus(t, w, http.StatusOK)
+			var body GetProductsResponse
+			decode(t, w, &body)
+			if body.Products == nil || len(body.Products) != 0 {
+				t.Errorf("expected an empty array, got %s", w.Body.String())
+			}
+		}
+
+		// Without filters an empty result is a valid answer either way.
+		server, _ = newTestServer(t, config)
+		expectStatus(t, serve(server, http.MethodGet, "/getProducts", ""), http.StatusOK)
+	}
+}
+
+// logRecords decodes the JSON records of logs with the given message.
+func logRecords(t *testing.T, logs *bytes.Buffer, msg string) []map[string]any {
+	t.Helper()
+
+	records := make([]map[string]any, 0)
+	decoder := json.NewDecoder(logs)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record["msg"] == msg {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestExcludedPathsAreNotLogged(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+	logs := captureLogs(t)
+
+	serve(server, http.MethodGet, "/health", "")
+	serve(server, http.MethodGet, "/getProducts", "")
+
+	records := logRecords(t, logs, "service call")
+	if len(records) != 1 || records[0]["path"] != "/getProducts" {
+		t.Errorf("expected only /getProducts to be logged, got %v", records)
+	}
+}
+
+func TestServiceCallsAreLoggedWithTheirOutcome(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+	logs := captureLogs(t)
+
+	expectStatus(t, serve(server, http.MethodGet, "/getProduct/99", ""), http.StatusNotFound)
+
+	records := logRecords(t, logs, "service call")
+	if len(records) != 1 {
+		t.Fatalf("expected one service call record, got %v", records)
+	}
+	record := records[0]
+	for key, want := range map[string]any{"serviceName": "getProduct", "method": "GET", "path": "/getProduct/99", "status": 404.0} {
+		if record[key] != want {
+			t.Errorf("expected %s %v, got %v", key, want, record[key])
+		}
+	}
+	if duration, ok := record["durationMs"].(float64); !ok || duration < 0 {
+		t.Errorf("expected a numeric durationMs, got %v", record["durationMs"])
+	}
+}
+
+func TestListingsFilterByIdRange(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), manyProducts()[:6]...)
+
+	for query, ids := range map[string][]int64{"idFrom=2&idTo=4": {2, 3, 4}, "idFrom=5": {5, 6}, "idTo=2": {1, 2}, "idFrom=3&idTo=3": {3}} {
+		w := serve(server, http.MethodGet, "/getProducts?"+query, "")
+		expectStatus(t, w, http.StatusOK)
+
+		var body GetProductsResponse
+		decode(t, w, &body)
+		got := make([]int64, len(body.Products))
+		for i, p := range body.Products {
+			got[i] = p.Id
+		}
+		if !slices.Equal(got, ids) {
+			t.Errorf("expected products %v for %s, got %v", ids, query, got)
+		}
+	}
+
+	w := serve(server, http.MethodGet, "/getProducts?idFrom=4&idTo=2", "")
+	expectStatus(t, w, http.StatusBadRequest)
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "idFrom must not be greater than idTo. Given: 4 and 2" {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+}
+
+func TestUpdateMovesUpdatedAtButNotCreatedAt(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	var before getProductResponse
+	decode(t, serve(server, http.MethodGet, "/getProduct/1", ""), &before)
+	if !before.UpdatedAt.Equal(before.CreatedAt) {
+		t.Errorf("expected a new product to have updatedAt equal to createdAt, got %s and %s", before.UpdatedAt, before.CreatedAt)
+	}
+
+	time.Sleep(time.Millisecond)
+	expectStatus(t, serve(server, http.MethodPut, "/updateProduct/1", `{"name":"Chair","code":"DSK-1"}`), http.StatusOK)
+
+	var after getProductResponse
+	decode(t, serve(server, http.MethodGet, "/getProduct/1", ""), &after)
+	if !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("expected createdAt to stay %s, got %s", before.CreatedAt, after.CreatedAt)
+	}
+	if !after.UpdatedAt.After(before.UpdatedAt) {
+		t.Errorf("expected updatedAt to move past %s, got %s", before.UpdatedAt, after.UpdatedAt)
+	}
+}
+
+func TestCountProductsAndListingTotalsRespectTheFilters(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), manyProducts()[:5]...)
+	expectStatus(t, serve(server, http.MethodPost, "/getProduct/2/deactivate", ""), http.StatusOK)
+
+	for query, total := range map[string]int64{"": 5, "status=active": 4, "status=inactive": 1, "idFrom=4": 2} {
+		var count CountProductsResponse
+		decode(t, serve(server, http.MethodGet, "/countProducts?"+query, ""), &count)
+		if count.Total != total {
+			t.Errorf("expected countProducts?%s to be %d, got %d", query, total, count.Total)
+		}
+
+		var page GetProductsPageResponse
+		decode(t, serve(server, http.MethodGet, "/getProducts?limit=1&"+query, ""), &page)
+		if page.Total != total || len(page.Products) != 1 {
+			t.Errorf("expected getProducts?%s to have a total of %d over 1 product, got %d over %d", query, total, page.Total, len(page.Products))
+		}
+	}
+}
+
+func TestListingsSort(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Chair", "B-1"), storage.NewProduct("Lamp", "A-1"), storage.NewProduct("Desk", "C-1"))
+
+	for sort, ids := range map[string][]int64{"": {1, 2, 3}, "name": {1, 3, 2}, "-name": {2, 3, 1}, "code": {2, 1, 3}, "-id": {3, 2, 1}} {
+		w := serve(server, http.MethodGet, "/getProducts?sort="+sort, "")
+		expectStatus(t, w, http.StatusOK)
+
+		var body GetProductsResponse
+		decode(t, w, &body)
+		got := make([]int64, len(body.Products))
+		for i, p := range body.Products {
+			got[i] = p.Id
+		}
+		if !slices.Equal(got, ids) {
+			t.Errorf("expected sort=%s to order %v, got %v", sort, ids, got)
+		}
+	}
+
+	w := serve(server, http.MethodGet, "/getProducts?sort="+url.QueryEscape("name;drop table product"), "")
+	expectStatus(t, w, http.StatusBadRequest)
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "sort must be one of id, name, code, createdAt or views, prefixed with - for descending order. Given: name;drop table product" {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+}
@@ -0,0 +1,325 @@
+package api
+
+import (
+	"apiGo/storage"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// maxBulkProducts bounds the number of products of a single bulk request.
+const maxBulkProducts = 1000
+
+// Resolutions of products of an import sharing a code, selected with the dedupe parameter.
+const (
+	DedupeError = "error" // Reject the import. The default.
+	DedupeFirst = "first" // Keep the first product with the code.
+	DedupeLast  = "last"  // Keep the last product with the code.
+)
+
+// BulkProductsRequest represents the request structure of the bulk product APIs.
+type BulkProductsRequest struct {
+	Products []*CreateProductRequest `json:"products"`
+}
+
+// ProductValidation is the validation outcome of one product of a bulk request.
+type ProductValidation struct {
+	Row      int      `json:"row"` // 1-based position in the request, such as the line of the CSV it came from.
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ValidateProductsResponse represents the response structure for validateProducts API.
+type ValidateProductsResponse struct {
+	Valid   bool                 `json:"valid"` // Whether every product is valid.
+	Results []*ProductValidation `json:"results"`
+}
+
+// decodeBulkProducts decodes a bulk request, rejecting empty and oversized ones.
+func decodeBulkProducts(r *http.Request) (*BulkProductsRequest, error) {
+	request := new(BulkProductsRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		return nil, decodeError(err)
+	}
+	if len(request.Products) == 0 {
+		return nil, newLocalizedError("validation.required", "products")
+	}
+	if len(request.Products) > maxBulkProducts {
+		return nil, newLocalizedError("products.tooMany", maxBulkProducts, len(request.Products))
+	}
+	return request, nil
+}
+
+// validateProducts runs the createProduct validation on every product of a bulk request, reporting each
+// row, without persisting anything.
+func (o *Server) validateProducts(w http.ResponseWriter, r *http.Request) error {
+	request, err := decodeBulkProducts(r)
+	if err != nil {
+		return err
+	}
+
+	response := ValidateProductsResponse{Valid: true, Results: make([]*ProductValidation, len(request.Products))}
+	for i, product := range request.Products {
+		result := &ProductValidation{Row: i + 1, Valid: true}
+		if product == nil {
+			product = new(CreateProductRequest)
+		}
+		warnings, errs := o.creationErrors(r, product)
+		if len(errs) > 0 {
+			result.Valid = false
+			result.Errors = errorMessages(r, errs)
+			response.Valid = false
+		}
+		result.Warnings = warnings
+		response.Results[i] = result
+	}
+
+	return writeJSON(w, http.StatusOK, response)
+}
+
+// errorMessages renders errs in the language of the request.
+func errorMessages(r *http.Request, errs []error) []string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = errorMessage(r, err)
+	}
+	return messages
+}
+
+// ndjsonContentType is the media type of newline-delimited JSON, used for streamed import progress.
+const ndjsonContentType = "application/x-ndjson"
+
+// importChunkSize is the number of rows inserted between two progress lines of a streamed import.
+const importChunkSize = 100
+
+// ImportProductsResponse represents the response structure for importProducts API.
+type ImportProductsResponse struct {
+	Products   []CreateProductResponse `json:"products"`
+	Duplicates int                     `json:"duplicates"` // Products dropped by the dedupe parameter.
+}
+
+// ImportProgress is a progress line of an importProducts response streamed as NDJSON.
+type ImportProgress struct {
+	Processed int    `json:"processed"`       // Rows handled so far, created or not.
+	Errors    int    `json:"errors"`          // Rows rejected so far.
+	Error     string `json:"error,omitempty"` // Why the import stopped early, on the last line.
+}
+
+// ImportRowErrors lists why a product of an import is invalid.
+type ImportRowErrors struct {
+	Row    int      `json:"row"` // 1-based position in the request, as reported by validateProducts.
+	Errors []string `json:"errors"`
+}
+
+// ImportErrorsResponse represents the response structure for importProducts API when products are invalid.
+type ImportErrorsResponse struct {
+	Error string             `json:"error"`
+	Rows  []*ImportRowErrors `json:"rows"`
+}
+
+// importRow is a row of an import once validated and deduplicated.
+type importRow struct {
+	position int              // 1-based position in the request.
+	product  *storage.Product // Nil when the row was dropped as a duplicate or is invalid.
+	warnings []string
+	errs     []error // Why the row is invalid.
+}
+
+// prepareImport validates every row of an import and resolves the codes they share according to dedupe.
+// Products without a code are never duplicates of each other.
+func (o *Server) prepareImport(r *http.Request, request *BulkProductsRequest, dedupe string) []*importRow {
+	rows := make([]*importRow, len(request.Products))
+	byCode := make(map[string]int)
+	for i, product := range request.Products {
+		if product == nil {
+			product = new(CreateProductRequest)
+		}
+		row := &importRow{position: i + 1}
+		rows[i] = row
+
+		if row.warnings, row.errs = o.creationErrors(r, product); len(row.errs) > 0 {
+			continue
+		}
+
+		row.product = product.newProduct()
+		if product.Code == "" {
+			continue
+		}
+
+		previous, found := byCode[product.Code]
+		switch {
+		case !found:
+			byCode[product.Code] = i
+		case dedupe == DedupeError:
+			row.product = nil
+			row.errs = []error{newLocalizedError("import.duplicateCode", product.Code, previous+1, i+1)}
+		case dedupe == DedupeFirst:
+			row.product = nil
+		case dedupe == DedupeLast:
+			rows[previous].product = nil
+			byCode[product.Code] = i
+		}
+	}
+	return rows
+}
+
+// importProducts creates the products of a bulk request. Products sharing a code are resolved by the
+// dedupe parameter.
+//
+// By default the products are created with a single multi-row insert, and nothing is created unless
+// every row is valid; otherwise the errors of every invalid row are answered with 422. Clients accepting application/x-ndjson instead get a progress line every
+// importChunkSize rows: invalid rows are counted as errors and skipped, and each chunk is committed
+// on its own, so an import stopped early keeps the chunks already reported.
+func (o *Server) importProducts(w http.ResponseWriter, r *http.Request) error {
+	dedupe := r.URL.Query().Get("dedupe")
+	if dedupe == "" {
+		dedupe = DedupeError
+	}
+	if dedupe != DedupeError && dedupe != DedupeFirst && dedupe != DedupeLast {
+		return newLocalizedError("import.invalidDedupe", dedupe)
+	}
+
+	request, err := decodeBulkProducts(r)
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), ndjsonContentType) {
+		release, err := o.acquireStream()
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return o.streamImport(w, r, o.prepareImport(r, request, dedupe))
+	}
+	rows := o.prepareImport(r, request, dedupe)
+
+	// Every invalid row is reported at once, so clients can fix them all before retrying.
+	invalid := make([]*ImportRowErrors, 0)
+	for i, row := range rows {
+		if len(row.errs) > 0 {
+			invalid = append(invalid, &ImportRowErrors{Row: i + 1, Errors: errorMessages(r, row.errs)})
+		}
+	}
+	if len(invalid) > 0 {
+		return writeJSON(w, http.StatusUnprocessableEntity, ImportErrorsResponse{
+			Error: localize(r, "import.invalid", len(invalid)),
+			Rows:  invalid,
+		})
+	}
+
+	products := make([]*storage.Product, 0, len(rows))
+	kept := make([]*importRow, 0, len(rows))
+	for _, row := range rows {
+		if row.product != nil {
+			products = append(products, row.product)
+			kept = append(kept, row)
+		}
+	}
+
+	products, err = o.db.CreateProducts(r.Context(), products)
+	if errors.Is(err, storage.ErrQuotaExceeded) {
+		return newAPIError(http.StatusForbidden, "product.quotaExceeded")
+	}
+	// A row the database rejects, such as one with the code of a stored product, is reported as invalid.
+	var rejected *storage.RowError
+	if errors.As(err, &rejected) {
+		return writeJSON(w, http.StatusUnprocessableEntity, ImportErrorsResponse{
+			Error: localize(r, "import.invalid", 1),
+			Rows:  []*ImportRowErrors{{Row: kept[rejected.Index].position, Errors: []string{rejectedRowMessage(r, rejected)}}},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	response := ImportProductsResponse{
+		Products:   make([]CreateProductResponse, len(products)),
+		Duplicates: len(rows) - len(products),
+	}
+	for i, p := range products {
+		o.changes.publish(p)
+		response.Products[i] = newCreateProductResponse(p, kept[i].warnings)
+	}
+
+	return writeJSON(w, http.StatusOK, response)
+}
+
+// importErrorMessage renders why a chunk of a streamed import couldn't be written, as the status of the
+// response has already been sent. Internal details are hidden and logged, as errorStatus does for others.
+func (o *Server) importErrorMessage(r *http.Request, err error) string {
+	var rejected *storage.RowError
+	switch {
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return localize(r, "product.quotaExceeded")
+	case errors.As(err, &rejected):
+		return rejectedRowMessage(r, rejected)
+	}
+
+	status, shown := errorStatus(err)
+	if status >= http.StatusInternalServerError {
+		slog.Error("streamed import stopped", "error", err.Error())
+	}
+	return errorMessage(r, shown)
+}
+
+// rejectedRowMessage renders why the storage rejected a row, in the language of the request.
+func rejectedRowMessage(r *http.Request, rejected *storage.RowError) string {
+	if errors.Is(rejected, storage.ErrDuplicateCode) {
+		return localize(r, "product.codeExists", rejected.Code)
+	}
+	_, shown := errorStatus(rejected.Err)
+	return errorMessage(r, shown)
+}
+
+// streamImport creates the rows of an import chunk by chunk, writing an NDJSON progress line after each.
+// A client that stops reading makes the writes fail, which stops the import.
+func (o *Server) streamImport(w http.ResponseWriter, r *http.Request, rows []*importRow) error {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	controller := http.NewResponseController(w)
+
+	progress := ImportProgress{}
+	for start := 0; start < len(rows); start += importChunkSize {
+		chunk := rows[start:min(start+importChunkSize, len(rows))]
+
+		products := make([]*storage.Product, 0, len(chunk))
+		for _, row := range chunk {
+			if len(row.errs) > 0 {
+				progress.Errors++
+			}
+			if row.product != nil {
+				products = append(products, row.product)
+			}
+		}
+
+		products, err := o.db.CreateProducts(r.Context(), products)
+		if err != nil {
+			progress.Error = o.importErrorMessage(r, err)
+		} else {
+			progress.Processed += len(chunk)
+			for _, p := range products {
+				o.changes.publish(p)
+			}
+		}
+
+		if err := encoder.Encode(progress); err != nil {
+			slog.Error("import progress couldn't be written, stopping the import", "error", err.Error())
+			return nil
+		}
+		if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			slog.Error("import progress couldn't be flushed, stopping the import", "error", err.Error())
+			return nil
+		}
+		if progress.Error != "" {
+			return nil
+		}
+	}
+
+	return nil
+}
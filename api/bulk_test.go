@@ -0,0 +1,225 @@
+package api
+
+import (
+	"apiGo/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestImportReportsEveryInvalidRow(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodPost, "/importProducts", `{"products": [
+		{"name": "", "code": "DSK-1", "status": "archived"},
+		{"name": "Desk", "code": "DSK-2"},
+		{"name": "Chair", "code": "CHR-1"},
+		{"name": "Chair", "code": "CHR-1"}
+	]}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+
+	var body ImportErrorsResponse
+	decode(t, w, &body)
+	if len(body.Rows) != 2 || body.Rows[0].Row != 1 || body.Rows[1].Row != 4 {
+		t.Fatalf("unexpected rows: %+v", body.Rows)
+	}
+	expected := []string{"name is required", "status must be one of active, inactive or draft. Given: archived"}
+	if !slices.Equal(body.Rows[0].Errors, expected) {
+		t.Errorf("unexpected errors of the first row: %v", body.Rows[0].Errors)
+	}
+
+	// Nothing is created when a row is invalid.
+	w = serve(server, http.MethodGet, "/countProducts", "")
+	var count CountProductsResponse
+	decode(t, w, &count)
+	if count.Total != 0 {
+		t.Errorf("expected no product, got %d", count.Total)
+	}
+}
+
+func TestImportReportsRowsRejectedByTheStorage(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodPost, "/importProducts", `{"products": [
+		{"name": "Chair", "code": "CHR-1"},
+		{"name": "Table", "code": "TBL-1"},
+		{"name": "Desk", "code": "DSK-1"}
+	]}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+
+	var body ImportErrorsResponse
+	decode(t, w, &body)
+	expected := []string{`a product with code "DSK-1" already exists`}
+	if len(body.Rows) != 1 || body.Rows[0].Row != 3 || !slices.Equal(body.Rows[0].Errors, expected) {
+		t.Fatalf("unexpected rows: %+v", body.Rows)
+	}
+}
+
+// importBody returns an import request of n valid products.
+func importBody(n int) string {
+	products := make([]string, n)
+	for i := range products {
+		products[i] = fmt.Sprintf(`{"name": "Desk %d", "code": "DSK-%d"}`, i, i)
+	}
+	return `{"products": [` + strings.Join(products, ",") + `]}`
+}
+
+// importProgress decodes the NDJSON progress lines of a streamed import.
+func importProgress(t *testing.T, w *httptest.ResponseRecorder) []ImportProgress {
+	t.Helper()
+
+	lines := make([]ImportProgress, 0)
+	decoder := json.NewDecoder(w.Body)
+	for decoder.More() {
+		var line ImportProgress
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("invalid progress line: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestStreamedImportReportsIncreasingProgress(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodPost, "/importProducts", importBody(250), "Accept", ndjsonContentType)
+	expectStatus(t, w, http.StatusOK)
+	if contentType := w.Header().Get("Content-Type"); contentType != ndjsonContentType {
+		t.Fatalf("unexpected Content-Type %q", contentType)
+	}
+
+	lines := importProgress(t, w)
+	expected := []int{100, 200, 250}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d progress lines, got %+v", len(expected), lines)
+	}
+	for i, line := range lines {
+		if line.Processed != expected[i] || line.Errors != 0 || line.Error != "" {
+			t.Errorf("unexpected progress line %d: %+v", i, line)
+		}
+	}
+}
+
+// failingInserter is a MemStorage whose multi-row inserts fail after the first one.
+type failingInserter struct {
+	*storage.MemStorage
+	inserts int
+}
+
+func (o *failingInserter) CreateProducts(ctx context.Context, products []*storage.Product) ([]*storage.Product, error) {
+	o.inserts++
+	if o.inserts > 1 {
+		return nil, errors.New("pq: connection reset by peer")
+	}
+	return o.MemStorage.CreateProducts(ctx, products)
+}
+
+func TestStreamedImportHidesStorageErrors(t *testing.T) {
+	server := NewApiServerWithConfig(":0", &failingInserter{MemStorage: storage.NewMemStorage()}, DefaultConfig())
+	server.HandleEndpoints()
+
+	w := serve(server, http.MethodPost, "/importProducts", importBody(150), "Accept", ndjsonContentType)
+	expectStatus(t, w, http.StatusOK)
+
+	lines := importProgress(t, w)
+	if len(lines) != 2 || lines[0].Processed != 100 || lines[1].Processed != 100 {
+		t.Fatalf("unexpected progress: %+v", lines)
+	}
+	if lines[1].Error != "internal server error, please try again later" {
+		t.Errorf("unexpected error on the last line: %q", lines[1].Error)
+	}
+}
+
+func TestValidateProductsAcceptsAValidBatch(t *testing.T) {
+	server, db := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodPost, "/validateProducts", `{"products":[{"name":"Desk","code":"DSK-1"},{"name":"Chair","code":"CHR-1"}]}`)
+	expectStatus(t, w, http.StatusOK)
+
+	var body ValidateProductsResponse
+	decode(t, w, &body)
+	if !body.Valid || len(body.Results) != 2 || !body.Results[0].Valid || !body.Results[1].Valid {
+		t.Errorf("expected every row to be valid, got %+v", body)
+	}
+	if count, _ := db.CountProducts(context.Background(), storage.ProductFilter{}); count != 0 {
+		t.Errorf("expected nothing to be persisted, got %d products", count)
+	}
+}
+
+func TestValidateProductsReportsEveryInvalidRow(t *testing.T) {
+	server, db := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodPost, "/validateProducts", `{"products":[{"name":"","code":"DSK-1"},{"name":"Chair","code":"CHR-1"},{"name":"Lamp"}]}`)
+	expectStatus(t, w, http.StatusOK)
+
+	var body ValidateProductsResponse
+	decode(t, w, &body)
+	if body.Valid || len(body.Results) != 3 {
+		t.Fatalf("expected the batch to be invalid, got %+v", body)
+	}
+	expected := []struct {
+		valid  bool
+		errors []string
+	}{
+		{false, []string{"name is required"}},
+		{true, nil},
+		{false, []string{"code is required"}},
+	}
+	for i, result := range body.Results {
+		if result.Row != i+1 || result.Valid != expected[i].valid || !slices.Equal(result.Errors, expected[i].errors) {
+			t.Errorf("unexpected result of row %d: %+v", i+1, result)
+		}
+	}
+	if count, _ := db.CountProducts(context.Background(), storage.ProductFilter{}); count != 0 {
+		t.Errorf("expected nothing to be persisted, got %d products", count)
+	}
+}
+
+func TestImportDedupeModes(t *testing.T) {
+	body := `{"products":[{"name":"Old chair","code":"CHR-1"},{"name":"Desk","code":"DSK-1"},{"name":"New chair","code":"CHR-1"}]}`
+
+	for dedupe, name := range map[string]string{DedupeFirst: "Old chair", DedupeLast: "New chair"} {
+		t.Run(dedupe, func(t *testing.T) {
+			server, db := newTestServer(t, DefaultConfig())
+
+			w := serve(server, http.MethodPost, "/importProducts?dedupe="+dedupe, body)
+			expectStatus(t, w, http.StatusOK)
+
+			var response ImportProductsResponse
+			decode(t, w, &response)
+			if len(response.Products) != 2 || response.Duplicates != 1 {
+				t.Fatalf("expected 2 products and 1 duplicate, got %+v", response)
+			}
+			product, err := db.GetProductByCode(context.Background(), "CHR-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if product.Name != name {
+				t.Errorf("expected %q to be kept, got %q", name, product.Name)
+			}
+		})
+	}
+
+	t.Run(DedupeError, func(t *testing.T) {
+		server, db := newTestServer(t, DefaultConfig())
+
+		w := serve(server, http.MethodPost, "/importProducts?dedupe="+DedupeError, body)
+		expectStatus(t, w, http.StatusUnprocessableEntity)
+
+		var response ImportErrorsResponse
+		decode(t, w, &response)
+		if len(response.Rows) != 1 || response.Rows[0].Row != 3 || !slices.Equal(response.Rows[0].Errors, []string{`code "CHR-1" is used by rows 1 and 3`}) {
+			t.Errorf("unexpected rows: %+v", response.Rows)
+		}
+		if count, _ := db.CountProducts(context.Background(), storage.ProductFilter{}); count != 0 {
+			t.Errorf("expected nothing to be created, got %d products", count)
+		}
+	})
+}
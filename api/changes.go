@@ -0,0 +1,130 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// changeHistorySize is the number of recent changes kept by the hub.
+const changeHistorySize = 1000
+
+// productChange is a snapshot of a product taken when it was created or updated.
+type productChange struct {
+	at      time.Time
+	product storage.Product
+}
+
+// changeHub records recent product changes and wakes up the clients waiting for them.
+// It is the single source of change notifications for push-style endpoints.
+type changeHub struct {
+	mu      sync.Mutex
+	changes []productChange // Ordered by time, oldest first.
+	notify  chan struct{}   // Closed and replaced on every change.
+}
+
+// newChangeHub creates an empty change hub.
+func newChangeHub() *changeHub {
+	return &changeHub{
+		changes: make([]productChange, 0, changeHistorySize),
+		notify:  make(chan struct{}),
+	}
+}
+
+// publish records a change of p and wakes up every waiting client.
+func (o *changeHub) publish(p *storage.Product) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.changes) == changeHistorySize {
+		o.changes = append(o.changes[:0], o.changes[1:]...)
+	}
+	o.changes = append(o.changes, productChange{at: time.Now().UTC(), product: *p})
+
+	close(o.notify)
+	o.notify = make(chan struct{})
+}
+
+// since returns the latest version of every product changed after t, the time of the
+// newest returned change, and a channel closed on the next change. Returning the channel
+// under the same lock guarantees no change is missed between both calls.
+func (o *changeHub) since(t time.Time) ([]*storage.Product, time.Time, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	products := make([]*storage.Product, 0)
+	positions := make(map[int64]int)
+	latest := t
+	for i := range o.changes {
+		change := o.changes[i]
+		if !change.at.After(t) {
+			continue
+		}
+		latest = change.at
+		p := change.product
+		if pos, ok := positions[p.Id]; ok {
+			products[pos] = &p
+			continue
+		}
+		positions[p.Id] = len(products)
+		products = append(products, &p)
+	}
+
+	return products, latest, o.notify
+}
+
+// LongPollChangesResponse represents the response structure for the long-polling changes API.
+type LongPollChangesResponse struct {
+	Products []*storage.Product `json:"products"`
+	Next     time.Time          `json:"next"` // Value to send as since on the next poll.
+}
+
+// longPollChanges blocks until a product changes after the since timestamp or the timeout elapses.
+func (o *Server) longPollChanges(w http.ResponseWriter, r *http.Request) error {
+	since := time.Now().UTC()
+	if value := r.URL.Query().Get("since"); value != "" {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return newLocalizedError("longpoll.invalidSince", value)
+		}
+		since = t
+	}
+
+	timeout := o.config.LongPollTimeout
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return newLocalizedError("longpoll.invalidTimeout", value)
+		}
+		timeout = min(d, o.config.LongPollMaxTimeout)
+	}
+
+	// A long poll holds its connection until a change or the timeout, like a stream.
+	release, err := o.acquireStream()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		products, next, changed := o.changes.since(since)
+		if len(products) > 0 {
+			return writeJSON(w, http.StatusOK, LongPollChangesResponse{Products: products, Next: next})
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return writeJSON(w, http.StatusOK, LongPollChangesResponse{Products: products, Next: next})
+		case <-o.stopping:
+			// Answered early so waiting clients don't hold the shutdown; they poll again elsewhere.
+			return writeJSON(w, http.StatusOK, LongPollChangesResponse{Products: products, Next: next})
+		case <-r.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAdminBulkChangesArePublished(t *testing.T) {
+	routes := []struct{ target, body string }{
+		{"/admin/normalizeCodes", ""},
+		{"/admin/renameSubstring", `{"field":"code","from":"dsk","to":"DSK"}`},
+	}
+
+	for _, route := range routes {
+		t.Run(route.target, func(t *testing.T) {
+			config := DefaultConfig()
+			config.AdminAPIKey = "secret"
+			server, _ := newTestServer(t, config, storage.NewProduct("Desk", "dsk-1"), storage.NewProduct("Chair", "CHR-1"))
+			since := time.Now().UTC()
+
+			w := serve(server, http.MethodPost, route.target, route.body, "Authorization", "Bearer secret")
+			expectStatus(t, w, http.StatusOK)
+
+			w = serve(server, http.MethodGet, "/changes/longpoll?timeout=1ms&since="+url.QueryEscape(since.Format(time.RFC3339Nano)), "")
+			expectStatus(t, w, http.StatusOK)
+
+			var body LongPollChangesResponse
+			decode(t, w, &body)
+			if len(body.Products) != 1 || body.Products[0].Id != 1 || body.Products[0].Code != "DSK-1" {
+				t.Errorf("expected the change of product 1, got %+v", body.Products)
+			}
+		})
+	}
+}
+
+// longPoll polls the changes since the given time, waiting at most timeout.
+func longPoll(t *testing.T, server *Server, since time.Time, timeout string) LongPollChangesResponse {
+	t.Helper()
+
+	w := serve(server, http.MethodGet, "/changes/longpoll?timeout="+timeout+"&since="+url.QueryEscape(since.Format(time.RFC3339Nano)), "")
+	expectStatus(t, w, http.StatusOK)
+
+	var body LongPollChangesResponse
+	decode(t, w, &body)
+	return body
+}
+
+func TestLongPollReturnsPendingChangesRightAway(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+	since := time.Now().UTC()
+	expectStatus(t, serve(server, http.MethodPost, "/createProduct", `{"name":"Desk","code":"DSK-1"}`), http.StatusOK)
+
+	start := time.Now()
+	body := longPoll(t, server, since, "10s")
+	if len(body.Products) != 1 || body.Products[0].Code != "DSK-1" {
+		t.Errorf("expected the created product, got %+v", body.Products)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the poll to return right away, it took %s", elapsed)
+	}
+}
+
+func TestLongPollReturnsNothingOnTimeout(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	start := time.Now()
+	body := longPoll(t, server, time.Now().UTC(), "50ms")
+	if len(body.Products) != 0 {
+		t.Errorf("expected no changes, got %+v", body.Products)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected the poll to wait for the timeout, it returned after %s", elapsed)
+	}
+}
+
+func TestLongPollWakesUpOnAChange(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+	since := time.Now().UTC()
+
+	time.AfterFunc(50*time.Millisecond, func() {
+		serve(server, http.MethodPatch, "/patchProduct/1", `{"name":"Chair"}`)
+	})
+
+	start := time.Now()
+	body := longPoll(t, server, since, "10s")
+	if len(body.Products) != 1 || body.Products[0].Name != "Chair" {
+		t.Errorf("expected the renamed product, got %+v", body.Products)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the poll to return on the change, it took %s", elapsed)
+	}
+}
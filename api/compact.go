@@ -0,0 +1,32 @@
+package api
+
+import (
+	"apiGo/storage"
+	"time"
+)
+
+// CompactProduct represents a product rendered with omitEmpty=true: fields holding their zero value,
+// such as an empty code or no views, are left out. The ID is always present.
+type CompactProduct struct {
+	Id        int64      `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Code      string     `json:"code,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	Views     int64      `json:"views,omitempty"`
+
+	Links *ProductLinks `json:"_links,omitempty"`
+}
+
+// compactProduct converts p into its CompactProduct.
+func compactProduct(p *storage.Product) CompactProduct {
+	compact := CompactProduct{Id: p.Id, Name: p.Name, Code: p.Code, Status: p.Status, Views: p.Views}
+	if !p.CreatedAt.IsZero() {
+		compact.CreatedAt = &p.CreatedAt
+	}
+	if !p.UpdatedAt.IsZero() {
+		compact.UpdatedAt = &p.UpdatedAt
+	}
+	return compact
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"testing"
+)
+
+func TestOmitEmptyLeavesOutZeroFields(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", ""))
+
+	for _, target := range []string{"/getProduct/1", "/getProducts"} {
+		for omitEmpty, present := range map[string]bool{"true": false, "false": true} {
+			w := serve(server, http.MethodGet, target+"?omitEmpty="+omitEmpty, "")
+			expectStatus(t, w, http.StatusOK)
+
+			product := make(map[string]any)
+			if target == "/getProducts" {
+				var body struct {
+					Products []map[string]any `json:"products"`
+				}
+				decode(t, w, &body)
+				product = body.Products[0]
+			} else {
+				decode(t, w, &product)
+			}
+
+			for _, field := range []string{"code", "views"} {
+				if _, found := product[field]; found != present {
+					t.Errorf("%s?omitEmpty=%s: expected %s to be present %v, got %v", target, omitEmpty, field, present, product)
+				}
+			}
+			if product["id"] != 1.0 || product["name"] != "Desk" {
+				t.Errorf("%s?omitEmpty=%s: expected the set fields, got %v", target, omitEmpty, product)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"apiGo/storage"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// FieldComparison compares a field of two products.
+type FieldComparison struct {
+	Field string `json:"field"`
+	A     any    `json:"a"`
+	B     any    `json:"b"`
+	Equal bool   `json:"equal"`
+}
+
+// CompareProductsResponse represents the response structure for compareProducts API.
+type CompareProductsResponse struct {
+	Fields []FieldComparison `json:"fields"`
+}
+
+// compareProducts compares the products identified by the a and b parameters field by field.
+func (o *Server) compareProducts(w http.ResponseWriter, r *http.Request) error {
+	a, err := o.getProductParam(r, "a")
+	if err != nil {
+		return err
+	}
+	b, err := o.getProductParam(r, "b")
+	if err != nil {
+		return err
+	}
+
+	response := CompareProductsResponse{Fields: []FieldComparison{
+		{Field: "name", A: a.Name, B: b.Name, Equal: a.Name == b.Name},
+		{Field: "code", A: a.Code, B: b.Code, Equal: a.Code == b.Code},
+		{Field: "createdAt", A: a.CreatedAt, B: b.CreatedAt, Equal: a.CreatedAt.Equal(b.CreatedAt)},
+		{Field: "status", A: a.Status, B: b.Status, Equal: a.Status == b.Status},
+	}}
+
+	return writeJSON(w, http.StatusOK, response)
+}
+
+// getProductParam retrieves the product whose ID is given by the named query parameter.
+func (o *Server) getProductParam(r *http.Request, name string) (*storage.Product, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, newLocalizedError("validation.required", name)
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, newLocalizedError("id.notNumeric", value)
+	}
+
+	p, err := o.db.GetProductById(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, productNotFound(id)
+	}
+	return p, err
+}
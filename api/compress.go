@@ -0,0 +1,167 @@
+package api
+
+import (
+	"apiGo/features"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressors creates the encoders of the supported content codings.
+var compressors = map[string]func(io.Writer) io.WriteCloser{
+	"br":   func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+	"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+}
+
+// flushableWriter is implemented by encoders able to flush a partially compressed stream.
+type flushableWriter interface {
+	Flush() error
+}
+
+// compressResponseWriter is a http.ResponseWriter that buffers the beginning of the body and only
+// compresses it once it reaches the threshold. Smaller bodies are sent as they are.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool           // Whether the body is being sent, compressed or not.
+	encoder io.WriteCloser // Set when the body is compressed.
+}
+
+// WriteHeader delays the status code until it's known whether the body is compressed.
+func (o *compressResponseWriter) WriteHeader(status int) {
+	if o.decided || o.status != 0 {
+		return
+	}
+	o.status = status
+}
+
+// Write buffers b until the threshold is reached, then compresses everything written so far.
+func (o *compressResponseWriter) Write(b []byte) (int, error) {
+	if !o.decided {
+		o.buf = append(o.buf, b...)
+		if len(o.buf) < o.minSize {
+			return len(b), nil
+		}
+		if err := o.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if o.encoder != nil {
+		return o.encoder.Write(b)
+	}
+	return o.ResponseWriter.Write(b)
+}
+
+// Flush sends what was written so far, compressed, so streamed responses aren't held back.
+func (o *compressResponseWriter) Flush() {
+	if !o.decided {
+		if err := o.start(true); err != nil {
+			return
+		}
+	}
+	if encoder, ok := o.encoder.(flushableWriter); ok {
+		_ = encoder.Flush()
+	}
+	_ = http.NewResponseController(o.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped writer so http.ResponseController can reach it.
+func (o *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return o.ResponseWriter
+}
+
+// Close sends a body that stayed below the threshold, or terminates the compressed stream.
+func (o *compressResponseWriter) Close() error {
+	if !o.decided {
+		return o.start(false)
+	}
+	if o.encoder != nil {
+		return o.encoder.Close()
+	}
+	return nil
+}
+
+// start sends the headers and the buffered bytes, compressed or not.
+func (o *compressResponseWriter) start(compress bool) error {
+	o.decided = true
+	if o.status == 0 {
+		o.status = http.StatusOK
+	}
+
+	header := o.Header()
+	if compress && header.Get("Content-Encoding") == "" && bodyAllowed(o.status) {
+		header.Set("Content-Encoding", o.encoding)
+		header.Del("Content-Length")
+		o.encoder = compressors[o.encoding](o.ResponseWriter)
+	}
+
+	o.ResponseWriter.WriteHeader(o.status)
+	if len(o.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if o.encoder != nil {
+		_, err = o.encoder.Write(o.buf)
+	} else {
+		_, err = o.ResponseWriter.Write(o.buf)
+	}
+	o.buf = nil
+	return err
+}
+
+// bodyAllowed reports whether a response with the given status may have a body.
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// negotiateEncoding returns the enabled content coding most preferred by the client, or "" for none.
+// Equally weighted codings are resolved in the order of the enabled list.
+func negotiateEncoding(acceptEncoding string, enabled []string) string {
+	best, bestQuality := "", 0.0
+	for _, preference := range parseQualityList(acceptEncoding) {
+		if preference.quality <= 0 || (best != "" && preference.quality < bestQuality) {
+			break
+		}
+		encoding := preference.value
+		if encoding == "*" && len(enabled) > 0 {
+			encoding = enabled[0]
+		}
+		i := slices.Index(enabled, encoding)
+		if i >= 0 && (best == "" || i < slices.Index(enabled, best)) {
+			best, bestQuality = encoding, preference.quality
+		}
+	}
+	return best
+}
+
+// interceptCompression is a middleware that compresses responses with the coding negotiated from
+// Accept-Encoding, when their body reaches Config.CompressionMinBytes.
+func (o *Server) interceptCompression(f http.HandlerFunc) http.HandlerFunc {
+	if len(o.config.CompressionAlgorithms) == 0 || !o.config.Features.Enabled(features.Compression) {
+		return f
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), o.config.CompressionAlgorithms)
+		if encoding == "" || r.Method == http.MethodHead {
+			f(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding, minSize: o.config.CompressionMinBytes}
+		f(cw, r)
+		_ = cw.Close()
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"apiGo/storage"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// manyProducts returns enough products for a listing of them to be compressed.
+func manyProducts() []*storage.Product {
+	products := make([]*storage.Product, 50)
+	for i := range products {
+		products[i] = storage.NewProduct(fmt.Sprintf("Product %d", i), fmt.Sprintf("PRD-%d", i))
+	}
+	return products
+}
+
+func TestCompressionPicksThePreferredAlgorithm(t *testing.T) {
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	}
+	tests := []struct{ acceptEncoding, encoding string }{
+		{"gzip", "gzip"},
+		{"gzip;q=0.5, br", "br"},
+		{"gzip, br", "br"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.acceptEncoding, func(t *testing.T) {
+			server, _ := newTestServer(t, DefaultConfig(), manyProducts()...)
+
+			w := serve(server, http.MethodGet, "/getProducts?limit=50", "", "Accept-Encoding", test.acceptEncoding)
+			expectStatus(t, w, http.StatusOK)
+			if got := w.Header().Get("Content-Encoding"); got != test.encoding {
+				t.Fatalf("expected Content-Encoding %q, got %q", test.encoding, got)
+			}
+
+			reader, err := decoders[test.encoding](w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body map[string]any
+			if err := json.NewDecoder(reader).Decode(&body); err != nil {
+				t.Fatalf("the body doesn't decompress to JSON: %v", err)
+			}
+		})
+	}
+}
+
+func TestSmallResponsesStayUncompressed(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodGet, "/getProduct/1", "", "Accept-Encoding", "br, gzip")
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected no Content-Encoding, got %q", got)
+	}
+
+	var body getProductResponse
+	decode(t, w, &body)
+	if body.Code != "DSK-1" {
+		t.Errorf("unexpected product: %+v", body)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// inFlightCounter tracks the requests being served for each client IP, along with a moving
+// average of how long requests take to estimate when a throttled client may retry.
+type inFlightCounter struct {
+	mu      sync.Mutex
+	starts  map[string][]time.Time
+	average time.Duration
+}
+
+// newInFlightCounter creates an empty inFlightCounter.
+func newInFlightCounter() *inFlightCounter {
+	return &inFlightCounter{starts: make(map[string][]time.Time)}
+}
+
+// acquire records a new request of ip and returns when it started, unless ip already has limit
+// requests in flight.
+func (o *inFlightCounter) acquire(ip string, limit int) (time.Time, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.starts[ip]) >= limit {
+		return time.Time{}, false
+	}
+	start := time.Now()
+	o.starts[ip] = append(o.starts[ip], start)
+	return start, true
+}
+
+// release records the end of the request of ip started at start and folds its duration into the
+// average. IPs without requests left are forgotten, so the map only holds the clients currently
+// connected.
+func (o *inFlightCounter) release(ip string, start time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	elapsed := time.Since(start)
+	if o.average == 0 {
+		o.average = elapsed
+	} else {
+		o.average += (elapsed - o.average) / 8
+	}
+
+	starts := o.starts[ip]
+	if i := slices.Index(starts, start); i >= 0 {
+		starts = slices.Delete(starts, i, i+1)
+	}
+	if len(starts) == 0 {
+		delete(o.starts, ip)
+		return
+	}
+	o.starts[ip] = starts
+}
+
+// retryAfter estimates in whole seconds when ip may have a request slot again: the time its
+// oldest request in flight still needs to reach the average duration, and at least a second.
+func (o *inFlightCounter) retryAfter(ip string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	starts := o.starts[ip]
+	if len(starts) == 0 {
+		return 1
+	}
+	remaining := o.average - time.Since(slices.MinFunc(starts, time.Time.Compare))
+	return max(1, int(math.Ceil(remaining.Seconds())))
+}
+
+// clientIP returns the IP address of the client, or the whole remote address when it has no port,
+// as with Unix sockets.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// interceptClientConcurrency is a middleware that answers 429 to a client IP which already has
+// Config.MaxConcurrentPerIP requests in flight, with a Retry-After header estimated from how long
+// requests take. Zero disables the limit.
+func (o *Server) interceptClientConcurrency(f apiFunc) apiFunc {
+	if o.config.MaxConcurrentPerIP <= 0 {
+		return f
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ip := clientIP(r)
+		start, ok := o.inFlight.acquire(ip, o.config.MaxConcurrentPerIP)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(o.inFlight.retryAfter(ip)))
+			return newAPIError(http.StatusTooManyRequests, "client.tooManyConcurrent", o.config.MaxConcurrentPerIP)
+		}
+		defer o.inFlight.release(ip, start)
+
+		return f(w, r)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientConcurrencyIsCappedPerIP(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConcurrentPerIP = 3
+	server, started, release := slowServer(":0", config)
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, 4)
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- serve(server, http.MethodGet, "/slow", "").Code
+		}()
+		<-started
+	}
+
+	w := serve(server, http.MethodGet, "/slow", "")
+	expectStatus(t, w, http.StatusTooManyRequests)
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "too many concurrent requests from this client, at most 3 are allowed" {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+
+	// Another client isn't affected: its request reaches the handler.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		r.RemoteAddr = "198.51.100.7:4321"
+		other := httptest.NewRecorder()
+		server.serverMux.ServeHTTP(other, r)
+		statuses <- other.Code
+	}()
+	<-started
+
+	close(release)
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("expected the requests within the cap to succeed, got %d", status)
+		}
+	}
+	if len(server.inFlight.starts) != 0 {
+		t.Errorf("expected the counters to be cleaned up, got %v", server.inFlight.starts)
+	}
+}
+
+func TestClientConcurrencyRetryAfterFollowsTheAverageDuration(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConcurrentPerIP = 1
+	server, started, release := slowServer(":0", config)
+	defer close(release)
+
+	go serve(server, http.MethodGet, "/slow", "")
+	<-started
+
+	// Without a finished request to go by, the client is told to retry in a second.
+	w := serve(server, http.MethodGet, "/slow", "")
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if retry := w.Header().Get("Retry-After"); retry != "1" {
+		t.Errorf("expected Retry-After 1 without history, got %q", retry)
+	}
+
+	server.inFlight.mu.Lock()
+	server.inFlight.average = 3 * time.Second
+	server.inFlight.mu.Unlock()
+
+	w = serve(server, http.MethodGet, "/slow", "")
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if retry := w.Header().Get("Retry-After"); retry != "3" {
+		t.Errorf("expected Retry-After 3 with requests taking 3s, got %q", retry)
+	}
+}
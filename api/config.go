@@ -0,0 +1,270 @@
+package api
+
+import (
+	"apiGo/env"
+	"apiGo/features"
+	"apiGo/storage"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces secrets when the configuration is exposed.
+const redactedValue = "***"
+
+// Config holds the tunable settings of the API server.
+type Config struct {
+	AsyncCreate bool   `json:"asyncCreate"` // Answer createProduct with 202 once queued, without waiting for the batched write.
+	AdminAPIKey string `json:"adminApiKey"` // Bearer token required by /admin endpoints. Empty disables them.
+
+	WarningsAsErrors  bool   `json:"warningsAsErrors"`  // Reject products breaking advisory rules instead of warning about them.
+	ControlCharacters string `json:"controlCharacters"` // ControlCharactersReject or ControlCharactersStrip.
+	CodeRequired      bool   `json:"codeRequired"`      // Reject products without a code.
+	GenerateCodes     bool   `json:"generateCodes"`     // Let the database generate the code of products saved without one.
+	StrictQuery       bool   `json:"strictQuery"`       // Reject requests with unknown query parameters instead of ignoring them.
+	EmptyAs404        bool   `json:"emptyAs404"`        // Answer 404 instead of an empty list when the filters of a listing match nothing.
+	LengthUnit        string `json:"lengthUnit"`        // Unit of the name and code length limit, storage.LengthUnitRunes or storage.LengthUnitBytes.
+
+	LockTTL time.Duration `json:"lockTtl"` // Lifetime of a product lock before it expires.
+
+	MaxCreatedAtSkew time.Duration `json:"maxCreatedAtSkew"` // How far in the future a createdAt given by the client may be.
+
+	MaxResponseBytes int64 `json:"maxResponseBytes"` // Responses growing beyond this size are aborted. Zero disables the limit.
+
+	CompressionAlgorithms []string `json:"compressionAlgorithms"` // Enabled content codings, by preference on ties. Empty disables compression.
+	CompressionMinBytes   int      `json:"compressionMinBytes"`   // Smaller responses are sent uncompressed.
+
+	LongPollTimeout    time.Duration `json:"longPollTimeout"`    // Wait time of a long poll without a timeout parameter.
+	LongPollMaxTimeout time.Duration `json:"longPollMaxTimeout"` // Upper bound for the timeout parameter of a long poll.
+
+	WriteTimeout time.Duration `json:"writeTimeout"` // Time a request has to be answered, slow clients included. Zero disables it.
+
+	ShutdownTimeout time.Duration `json:"shutdownTimeout"` // Time in-flight requests have to finish once the server is asked to stop.
+
+	ViewsFlushInterval time.Duration `json:"viewsFlushInterval"` // Period of the writes of the product views counted in memory.
+
+	MaxJSONDepth int `json:"maxJsonDepth"` // Request bodies nesting objects and arrays deeper are rejected. Zero disables the limit.
+
+	MaxRequestBytes int64 `json:"maxRequestBytes"` // Request bodies larger are rejected. Zero disables the limit.
+
+	LogExcludedPaths []string `json:"logExcludedPaths"` // Paths not logged, such as frequently polled probes.
+
+	MaxConcurrentPerIP int `json:"maxConcurrentPerIp"` // Requests a client IP may have in flight at once. Zero disables the limit.
+
+	MaxConcurrentStreams int `json:"maxConcurrentStreams"` // Streaming responses served at once, NDJSON imports and long polls. Zero disables the limit.
+
+	MaxConcurrentRequests int           `json:"maxConcurrentRequests"` // Weight of the requests served at once, reads first when saturated. Zero disables admission.
+	BulkRequestWeight     int           `json:"bulkRequestWeight"`     // Weight of a bulk write, such as an import. Other requests weigh 1.
+	AdmissionQueueTimeout time.Duration `json:"admissionQueueTimeout"` // Time a request may wait for admission before 503.
+
+	HTTPSRedirect              bool     `json:"httpsRedirect"`              // Redirect requests the TLS terminating proxy received over plain HTTP.
+	HTTPSRedirectExcludedPaths []string `json:"httpsRedirectExcludedPaths"` // Paths served over plain HTTP too, such as health checks.
+
+	ProblemDetails bool `json:"problemDetails"` // Render every error as application/problem+json, not only for clients asking for it.
+
+	CORSAllowedOrigins []string `json:"corsAllowedOrigins"` // Origins browsers may call the API from, or "*" for any. Empty disables CORS.
+	CORSAllowedHeaders []string `json:"corsAllowedHeaders"` // Request headers cross-origin requests may send.
+
+	LinksBasePath string `json:"linksBasePath"` // Prefix of the links rendered with links=true, when a proxy serves the API under a path.
+
+	Features features.Flags `json:"features"` // Optional behaviors turned on or off.
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden.
+func DefaultConfig() Config {
+	return Config{
+		AsyncCreate: false,
+		AdminAPIKey: "",
+
+		WarningsAsErrors:  false,
+		ControlCharacters: ControlCharactersReject,
+		CodeRequired:      true,
+		GenerateCodes:     false,
+		StrictQuery:       false,
+		EmptyAs404:        false,
+		LengthUnit:        storage.LengthUnitRunes,
+
+		LockTTL: 5 * time.Minute,
+
+		MaxCreatedAtSkew: 5 * time.Minute,
+
+		MaxResponseBytes: 10 << 20,
+
+		CompressionAlgorithms: []string{"br", "gzip"},
+		CompressionMinBytes:   1024,
+
+		LongPollTimeout:    30 * time.Second,
+		LongPollMaxTimeout: 60 * time.Second,
+
+		WriteTimeout: 90 * time.Second,
+
+		ShutdownTimeout: 10 * time.Second,
+
+		ViewsFlushInterval: 10 * time.Second,
+
+		MaxJSONDepth: 5,
+
+		MaxRequestBytes: 1 << 20,
+
+		LogExcludedPaths: []string{"/health", "/ready", "/metrics", "/status"},
+
+		MaxConcurrentPerIP: 0,
+
+		MaxConcurrentStreams: 0,
+
+		MaxConcurrentRequests: 0,
+		BulkRequestWeight:     4,
+		AdmissionQueueTimeout: 5 * time.Second,
+
+		HTTPSRedirect:              false,
+		HTTPSRedirectExcludedPaths: []string{"/health", "/ready", "/status"},
+
+		ProblemDetails: false,
+
+		CORSAllowedOrigins: []string{},
+		CORSAllowedHeaders: []string{"Content-Type", "Authorization", "Accept-Language", "If-None-Match", "Content-MD5", "Digest", lockHolderHeader},
+
+		LinksBasePath: "",
+
+		Features: features.Defaults(),
+	}
+}
+
+// Redacted returns a copy of the configuration with secrets masked, safe to expose.
+// Every secret field added to Config must be masked here.
+func (o Config) Redacted() Config {
+	if o.AdminAPIKey != "" {
+		o.AdminAPIKey = redactedValue
+	}
+	return o
+}
+
+// LoadConfig reads the configuration from environment variables, falling back to DefaultConfig.
+func LoadConfig() (Config, error) {
+	var err error
+	config := DefaultConfig()
+
+	if config.AsyncCreate, err = env.Bool("BATCH_ASYNC", config.AsyncCreate); err != nil {
+		return config, err
+	}
+	config.AdminAPIKey = env.String("ADMIN_API_KEY", config.AdminAPIKey)
+	if config.WarningsAsErrors, err = env.Bool("VALIDATION_WARNINGS_AS_ERRORS", config.WarningsAsErrors); err != nil {
+		return config, err
+	}
+	config.ControlCharacters = env.String("CONTROL_CHARACTERS", config.ControlCharacters)
+	if config.ControlCharacters != ControlCharactersReject && config.ControlCharacters != ControlCharactersStrip {
+		return config, fmt.Errorf("CONTROL_CHARACTERS must be %s or %s. Given: %s", ControlCharactersReject, ControlCharactersStrip, config.ControlCharacters)
+	}
+	if config.CodeRequired, err = env.Bool("CODE_REQUIRED", config.CodeRequired); err != nil {
+		return config, err
+	}
+	if config.GenerateCodes, err = env.Bool("CODE_AUTO_GENERATE", config.GenerateCodes); err != nil {
+		return config, err
+	}
+	if config.StrictQuery, err = env.Bool("STRICT_QUERY", config.StrictQuery); err != nil {
+		return config, err
+	}
+	if config.EmptyAs404, err = env.Bool("EMPTY_LISTING_AS_404", config.EmptyAs404); err != nil {
+		return config, err
+	}
+	if config.LengthUnit, err = storage.LoadLengthUnit(); err != nil {
+		return config, err
+	}
+	if config.LockTTL, err = env.Duration("PRODUCT_LOCK_TTL", config.LockTTL); err != nil {
+		return config, err
+	}
+	if config.MaxCreatedAtSkew, err = env.Duration("CREATED_AT_MAX_SKEW", config.MaxCreatedAtSkew); err != nil {
+		return config, err
+	}
+	maxResponseBytes, err := env.Int("MAX_RESPONSE_BYTES", int(config.MaxResponseBytes))
+	if err != nil {
+		return config, err
+	}
+	config.MaxResponseBytes = int64(maxResponseBytes)
+	if value, ok := os.LookupEnv("COMPRESSION_ALGORITHMS"); ok {
+		config.CompressionAlgorithms = make([]string, 0)
+		for _, algorithm := range strings.Split(value, ",") {
+			algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+			if algorithm == "" {
+				continue
+			}
+			if _, ok := compressors[algorithm]; !ok {
+				return config, fmt.Errorf("COMPRESSION_ALGORITHMS only supports br and gzip. Given: %s", algorithm)
+			}
+			config.CompressionAlgorithms = append(config.CompressionAlgorithms, algorithm)
+		}
+	}
+	if config.CompressionMinBytes, err = env.Int("COMPRESSION_MIN_BYTES", config.CompressionMinBytes); err != nil {
+		return config, err
+	}
+	if config.LongPollTimeout, err = env.Duration("LONGPOLL_TIMEOUT", config.LongPollTimeout); err != nil {
+		return config, err
+	}
+	if config.LongPollMaxTimeout, err = env.Duration("LONGPOLL_MAX_TIMEOUT", config.LongPollMaxTimeout); err != nil {
+		return config, err
+	}
+	if config.WriteTimeout, err = env.Duration("HTTP_WRITE_TIMEOUT", config.WriteTimeout); err != nil {
+		return config, err
+	}
+	if config.ShutdownTimeout, err = env.Duration("SHUTDOWN_TIMEOUT", config.ShutdownTimeout); err != nil {
+		return config, err
+	}
+	if config.ViewsFlushInterval, err = env.Duration("VIEWS_FLUSH_INTERVAL", config.ViewsFlushInterval); err != nil {
+		return config, err
+	}
+	if config.ViewsFlushInterval <= 0 {
+		return config, fmt.Errorf("VIEWS_FLUSH_INTERVAL must be positive. Given: %s", config.ViewsFlushInterval)
+	}
+	if config.WriteTimeout > 0 && config.LongPollMaxTimeout >= config.WriteTimeout {
+		return config, fmt.Errorf("HTTP_WRITE_TIMEOUT must be longer than LONGPOLL_MAX_TIMEOUT. Given: %s", config.WriteTimeout)
+	}
+	if config.MaxJSONDepth, err = env.Int("JSON_MAX_DEPTH", config.MaxJSONDepth); err != nil {
+		return config, err
+	}
+	maxRequestBytes, err := env.Int("MAX_REQUEST_BYTES", int(config.MaxRequestBytes))
+	if err != nil {
+		return config, err
+	}
+	config.MaxRequestBytes = int64(maxRequestBytes)
+	if config.MaxConcurrentPerIP, err = env.Int("MAX_CONCURRENT_REQUESTS_PER_IP", config.MaxConcurrentPerIP); err != nil {
+		return config, err
+	}
+	if config.MaxConcurrentStreams, err = env.Int("MAX_CONCURRENT_STREAMS", config.MaxConcurrentStreams); err != nil {
+		return config, err
+	}
+	if config.MaxConcurrentRequests, err = env.Int("MAX_CONCURRENT_REQUESTS", config.MaxConcurrentRequests); err != nil {
+		return config, err
+	}
+	if config.BulkRequestWeight, err = env.Int("BULK_REQUEST_WEIGHT", config.BulkRequestWeight); err != nil {
+		return config, err
+	}
+	if config.BulkRequestWeight < 1 {
+		return config, fmt.Errorf("BULK_REQUEST_WEIGHT must be at least 1. Given: %d", config.BulkRequestWeight)
+	}
+	if config.AdmissionQueueTimeout, err = env.Duration("ADMISSION_QUEUE_TIMEOUT", config.AdmissionQueueTimeout); err != nil {
+		return config, err
+	}
+	if config.AdmissionQueueTimeout <= 0 {
+		return config, fmt.Errorf("ADMISSION_QUEUE_TIMEOUT must be positive. Given: %s", config.AdmissionQueueTimeout)
+	}
+	config.LogExcludedPaths = env.List("LOG_EXCLUDED_PATHS", config.LogExcludedPaths)
+	if config.HTTPSRedirect, err = env.Bool("HTTPS_REDIRECT", config.HTTPSRedirect); err != nil {
+		return config, err
+	}
+	config.HTTPSRedirectExcludedPaths = env.List("HTTPS_REDIRECT_EXCLUDED_PATHS", config.HTTPSRedirectExcludedPaths)
+	if config.ProblemDetails, err = env.Bool("PROBLEM_DETAILS", config.ProblemDetails); err != nil {
+		return config, err
+	}
+	config.CORSAllowedOrigins = env.List("CORS_ALLOWED_ORIGINS", config.CORSAllowedOrigins)
+	config.CORSAllowedHeaders = env.List("CORS_ALLOWED_HEADERS", config.CORSAllowedHeaders)
+	config.LinksBasePath = env.String("LINKS_BASE_PATH", config.LinksBasePath)
+	if config.LinksBasePath != "" && !strings.HasPrefix(config.LinksBasePath, "/") {
+		return config, fmt.Errorf("LINKS_BASE_PATH must start with /. Given: %s", config.LinksBasePath)
+	}
+	if config.Features, err = features.Load(); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsAnyOrigin in Config.CORSAllowedOrigins allows every origin.
+const corsAnyOrigin = "*"
+
+// corsExposedHeaders are the response headers browsers let scripts read, beyond the basic ones.
+var corsExposedHeaders = []string{totalCountHeader, "Content-Language"}
+
+// interceptCORS is a middleware that adds the CORS headers to the responses of requests coming from an
+// origin of Config.CORSAllowedOrigins, and answers their preflight requests with 204. The methods
+// allowed are the ones registered for path. Without allowed origins it does nothing.
+//
+// Preflight requests use OPTIONS, which no endpoint is registered for, so they reach the handler
+// registered for the remaining methods of the path, which must be wrapped too.
+func (o *Server) interceptCORS(path string, f http.HandlerFunc) http.HandlerFunc {
+	if len(o.config.CORSAllowedOrigins) == 0 {
+		return f
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		anyOrigin := slices.Contains(o.config.CORSAllowedOrigins, corsAnyOrigin)
+		if origin == "" || !anyOrigin && !slices.Contains(o.config.CORSAllowedOrigins, origin) {
+			f(w, r)
+			return
+		}
+
+		if anyOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", corsAnyOrigin)
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(o.methods[path], ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(o.config.CORSAllowedHeaders, ", "))
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		f(w, r)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	config := DefaultConfig()
+	config.CORSAllowedOrigins = []string{"https://app.example.com"}
+	server, _ := newTestServer(t, config, storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodOptions, "/getProduct/1", "",
+		"Origin", "https://app.example.com", "Access-Control-Request-Method", http.MethodGet)
+	expectStatus(t, w, http.StatusNoContent)
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.com",
+		"Access-Control-Allow-Methods": "GET, HEAD",
+		"Access-Control-Allow-Headers": "Content-Type, Authorization, Accept-Language, If-None-Match, Content-MD5, Digest, X-Lock-Holder",
+		"Vary":                         "Origin",
+	}
+	for header, value := range expected {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("%s: expected %q, got %q", header, value, got)
+		}
+	}
+}
+
+func TestCORSResponseHeaders(t *testing.T) {
+	config := DefaultConfig()
+	config.CORSAllowedOrigins = []string{corsAnyOrigin}
+	server, _ := newTestServer(t, config, storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodGet, "/getProduct/1", "", "Origin", "https://elsewhere.example.com")
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != corsAnyOrigin {
+		t.Errorf("expected any origin to be allowed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Total-Count, Content-Language" {
+		t.Errorf("unexpected exposed headers %q", got)
+	}
+}
+
+func TestCORSIgnoresOtherOrigins(t *testing.T) {
+	config := DefaultConfig()
+	config.CORSAllowedOrigins = []string{"https://app.example.com"}
+	server, _ := newTestServer(t, config)
+
+	w := serve(server, http.MethodOptions, "/getProduct/1", "",
+		"Origin", "https://evil.example.com", "Access-Control-Request-Method", http.MethodGet)
+	expectStatus(t, w, http.StatusMethodNotAllowed)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected allowed origin %q", got)
+	}
+}
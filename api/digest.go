@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// digestAlgorithms are the algorithms of the Digest header verified by interceptDigest.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// interceptDigest is a middleware that verifies the Content-MD5 and Digest headers of the request, when
+// present, against the body before it's decoded. Digest algorithms other than sha-256 and sha-512 are ignored.
+func interceptDigest(f apiFunc) apiFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		contentMD5 := r.Header.Get("Content-MD5")
+		digest := r.Header.Get("Digest")
+		if contentMD5 == "" && digest == "" {
+			return f(w, r)
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if contentMD5 != "" {
+			if err := verifyDigest("Content-MD5", contentMD5, md5.New, body); err != nil {
+				return err
+			}
+		}
+
+		for _, part := range strings.Split(digest, ",") {
+			algorithm, value, found := strings.Cut(strings.TrimSpace(part), "=")
+			if !found {
+				continue
+			}
+			newHash, ok := digestAlgorithms[strings.ToLower(algorithm)]
+			if !ok {
+				continue
+			}
+			if err := verifyDigest("Digest", value, newHash, body); err != nil {
+				return err
+			}
+		}
+
+		return f(w, r)
+	}
+}
+
+// verifyDigest checks that the base64 encoded digest sent in header matches the body.
+func verifyDigest(header, encoded string, newHash func() hash.Hash, body []byte) error {
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return newLocalizedError("digest.invalid", header)
+	}
+
+	h := newHash()
+	h.Write(body)
+	if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+		return newLocalizedError("digest.mismatch", header)
+	}
+	return nil
+}
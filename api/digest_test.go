@@ -0,0 +1,60 @@
+package api
+
+import (
+	"apiGo/storage"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+// sha256Digest returns the Digest header of body.
+func sha256Digest(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestDigestAcceptsMatchingBody(t *testing.T) {
+	server, db := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	body := `{"name":"Chair"}`
+	w := serve(server, http.MethodPatch, "/patchProduct/1", body, "Digest", sha256Digest(body))
+	expectStatus(t, w, http.StatusOK)
+
+	product, err := db.GetProductById(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Name != "Chair" {
+		t.Errorf("expected the product to be renamed, got %q", product.Name)
+	}
+}
+
+func TestDigestRejectsMismatchOnEveryBodyRoute(t *testing.T) {
+	routes := []struct{ method, target, body string }{
+		{http.MethodPost, "/createProduct", `{"name":"Chair","code":"CHR-1"}`},
+		{http.MethodPut, "/updateProduct/1", `{"name":"Chair","code":"DSK-1"}`},
+		{http.MethodPatch, "/patchProduct/1", `{"name":"Chair"}`},
+		{http.MethodPost, "/getProductsMap", `{"ids":[1]}`},
+		{http.MethodPost, "/validateProducts", `{"products":[{"name":"Chair","code":"CHR-1"}]}`},
+		{http.MethodPost, "/importProducts", `{"products":[{"name":"Chair","code":"CHR-1"}]}`},
+		{http.MethodPost, "/mergeProducts", `{"keepId":1,"mergeId":2}`},
+		{http.MethodPost, "/admin/renameSubstring", `{"field":"name","from":"Desk","to":"Table"}`},
+	}
+
+	for _, route := range routes {
+		t.Run(route.target, func(t *testing.T) {
+			server, _ := newTestServer(t, adminConfig("s3cret"), storage.NewProduct("Desk", "DSK-1"), storage.NewProduct("Desk", "DSK-2"))
+
+			w := serve(server, route.method, route.target, route.body, "Digest", sha256Digest(route.body+" "), "Authorization", "Bearer s3cret")
+			expectStatus(t, w, http.StatusBadRequest)
+
+			var body WebError
+			decode(t, w, &body)
+			if body.Error != "the request body doesn't match the Digest header" {
+				t.Errorf("unexpected error: %q", body.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"apiGo/storage"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// APIError is an error answered with a specific status code.
+type APIError struct {
+	Status  int    // HTTP status code of the response.
+	Message string // Message in the default language.
+
+	localized *localizedError // Catalog message, rendered in the language of the request.
+}
+
+// newAPIError creates an error answered with status and the catalog message key, formatted with args.
+func newAPIError(status int, key string, args ...any) *APIError {
+	localized := &localizedError{key: key, args: args}
+	return &APIError{Status: status, Message: localized.Error(), localized: localized}
+}
+
+// Error returns the message in the default language.
+func (o *APIError) Error() string {
+	return o.Message
+}
+
+// Unwrap returns the catalog message, so errorMessage can render it.
+func (o *APIError) Unwrap() error {
+	if o.localized == nil {
+		return nil
+	}
+	return o.localized
+}
+
+// decodeError is the error answered when a request body isn't valid JSON for the expected structure.
+func decodeError(err error) error {
+	if errors.Is(err, io.EOF) {
+		return newAPIError(http.StatusBadRequest, "body.missing")
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return newAPIError(http.StatusBadRequest, "body.invalid", err.Error())
+	}
+
+	// Anything else comes from reading the body, not from its content.
+	return err
+}
+
+// errorStatus picks the status code answering err. Errors without a known meaning are answered with
+// 500 and a generic message, so internal details aren't exposed to clients.
+func errorStatus(err error) (int, error) {
+	var apiErr *APIError
+	var localized *localizedError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Status, err
+	case errors.Is(err, storage.ErrNotInitialized):
+		return http.StatusServiceUnavailable, newLocalizedError("service.notInitialized")
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound, err
+	case errors.Is(err, storage.ErrInvalidData):
+		return http.StatusBadRequest, err
+	case errors.Is(err, storage.ErrDuplicateCode):
+		return http.StatusConflict, newLocalizedError("product.duplicateCode")
+	case errors.Is(err, storage.ErrModified):
+		return http.StatusConflict, newLocalizedError("product.modified")
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge, newLocalizedError("body.tooLarge", tooLarge.Limit)
+	case errors.As(err, &localized):
+		// Localized errors describe what the client got wrong.
+		return http.StatusBadRequest, err
+	default:
+		return http.StatusInternalServerError, newLocalizedError("service.internal")
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestMissingTableAnswersServiceUnavailable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("select .* from product where id=").
+		WillReturnError(&pq.Error{Code: "42P01", Message: `relation "product" does not exist`})
+
+	server := NewApiServerWithConfig(":0", storage.NewPgStorageWithDB(db), DefaultConfig())
+	server.HandleEndpoints()
+
+	w := serve(server, http.MethodGet, "/getProduct/1", "")
+	expectStatus(t, w, http.StatusServiceUnavailable)
+
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "service not initialized, please try again later" {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+)
+
+// interceptHTTPSRedirect is a middleware that, when Config.HTTPSRedirect is set, redirects to HTTPS the
+// requests the TLS terminating proxy received over plain HTTP, as reported by X-Forwarded-Proto.
+// Config.HTTPSRedirectExcludedPaths are served as they come, so health checks keep working.
+func (o *Server) interceptHTTPSRedirect(f http.HandlerFunc) http.HandlerFunc {
+	if !o.config.HTTPSRedirect {
+		return f
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") != "http" || slices.Contains(o.config.HTTPSRedirectExcludedPaths, r.URL.Path) {
+			f(w, r)
+			return
+		}
+
+		// 301 lets clients turn other methods into GET, so they get 308, which keeps the method and body.
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), status)
+	}
+}
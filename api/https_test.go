@@ -0,0 +1,25 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"testing"
+)
+
+func TestPlainHTTPRequestsAreRedirected(t *testing.T) {
+	config := DefaultConfig()
+	config.HTTPSRedirect = true
+	server, _ := newTestServer(t, config, storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodGet, "/getProduct/1?links=true", "", "X-Forwarded-Proto", "http")
+	expectStatus(t, w, http.StatusMovedPermanently)
+	if got := w.Header().Get("Location"); got != "https://example.com/getProduct/1?links=true" {
+		t.Errorf("unexpected Location: %q", got)
+	}
+
+	w = serve(server, http.MethodPatch, "/patchProduct/1", `{"name":"Chair"}`, "X-Forwarded-Proto", "http")
+	expectStatus(t, w, http.StatusPermanentRedirect)
+
+	expectStatus(t, serve(server, http.MethodGet, "/getProduct/1", "", "X-Forwarded-Proto", "https"), http.StatusOK)
+	expectStatus(t, serve(server, http.MethodGet, "/health", "", "X-Forwarded-Proto", "http"), http.StatusOK)
+}
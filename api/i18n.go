@@ -0,0 +1,98 @@
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// defaultLanguage is used when the client accepts none of the catalog languages.
+const defaultLanguage = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogs maps a language to its messages, keyed by message key.
+var catalogs = loadCatalogs()
+
+// loadCatalogs reads every embedded locale file. The file name is the language.
+func loadCatalogs() map[string]map[string]string {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	result := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("invalid locale file %s: %v", file.Name(), err))
+		}
+		result[strings.TrimSuffix(file.Name(), ".json")] = catalog
+	}
+
+	return result
+}
+
+// localizedError is an error whose message is looked up in the catalog of the client's language.
+type localizedError struct {
+	key  string
+	args []any
+}
+
+// newLocalizedError creates an error rendered from the catalog message key, formatted with args.
+func newLocalizedError(key string, args ...any) error {
+	return &localizedError{key: key, args: args}
+}
+
+// Error returns the message in the default language.
+func (o *localizedError) Error() string {
+	return translate(defaultLanguage, o.key, o.args...)
+}
+
+// translate formats the message key in the given language, falling back to the default language.
+func translate(language, key string, args ...any) string {
+	format, ok := catalogs[language][key]
+	if !ok {
+		format, ok = catalogs[defaultLanguage][key]
+	}
+	if !ok {
+		return key
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// localize formats the message key in the language requested by r.
+func localize(r *http.Request, key string, args ...any) string {
+	return translate(requestLanguage(r), key, args...)
+}
+
+// errorMessage renders err in the language requested by r when it is a localized error.
+func errorMessage(r *http.Request, err error) string {
+	var localized *localizedError
+	if errors.As(err, &localized) {
+		return translate(requestLanguage(r), localized.key, localized.args...)
+	}
+	return err.Error()
+}
+
+// requestLanguage picks the most preferred catalog language from the Accept-Language header.
+func requestLanguage(r *http.Request) string {
+	for _, preference := range parseQualityList(r.Header.Get("Accept-Language")) {
+		// Only the primary subtag is matched, so es-AR is served with the es catalog.
+		language, _, _ := strings.Cut(preference.value, "-")
+		if _, ok := catalogs[language]; ok && preference.quality > 0 {
+			return language
+		}
+	}
+
+	return defaultLanguage
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestValidationErrorsAreTranslated(t *testing.T) {
+	tests := []struct{ acceptLanguage, language, message string }{
+		{"es-AR, en;q=0.5", "es", "name es obligatorio"},
+		{"en", "en", "name is required"},
+		{"fr", "en", "name is required"},
+		{"", "en", "name is required"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.acceptLanguage, func(t *testing.T) {
+			server, _ := newTestServer(t, DefaultConfig())
+
+			w := serve(server, http.MethodPost, "/createProduct", `{"code":"DSK-1"}`, "Accept-Language", test.acceptLanguage)
+			expectStatus(t, w, http.StatusBadRequest)
+
+			var body WebError
+			decode(t, w, &body)
+			if body.Error != test.message {
+				t.Errorf("expected %q, got %q", test.message, body.Error)
+			}
+			if got := w.Header().Get("Content-Language"); got != test.language {
+				t.Errorf("expected Content-Language %q, got %q", test.language, got)
+			}
+		})
+	}
+}
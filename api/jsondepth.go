@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// interceptJSONDepth is a middleware that rejects request bodies nesting objects and arrays deeper than
+// Config.MaxJSONDepth, before anything decodes them. Zero disables the check.
+func (o *Server) interceptJSONDepth(f apiFunc) apiFunc {
+	if o.config.MaxJSONDepth <= 0 {
+		return f
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if jsonDepth(body, o.config.MaxJSONDepth) > o.config.MaxJSONDepth {
+			return newLocalizedError("body.tooDeep", o.config.MaxJSONDepth)
+		}
+
+		return f(w, r)
+	}
+}
+
+// jsonDepth returns the nesting depth of the objects and arrays of a JSON document, scanning
+// no further than the first point deeper than limit. Malformed documents are left to the decoder.
+func jsonDepth(body []byte, limit int) int {
+	depth, maxDepth := 0, 0
+	inString, escaped := false, false
+	for _, c := range body {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+			if depth > maxDepth {
+				maxDepth = depth
+				if maxDepth > limit {
+					return maxDepth
+				}
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return maxDepth
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"apiGo/storage"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+)
+
+// jsonPatchContentType is the media type of RFC 6902 JSON Patch documents.
+const jsonPatchContentType = "application/json-patch+json"
+
+// patchOperation is a single operation of a JSON Patch document.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// isJSONPatch reports whether the request body is a JSON Patch document.
+func isJSONPatch(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == jsonPatchContentType
+}
+
+// patchProduct applies a JSON Patch document to the product identified in the URL. Only /name and /code
+// can be modified, with add or replace. A failing test operation answers 409 and nothing is persisted,
+// as does a change of the product by someone else between the read and the update.
+func (o *Server) patchProduct(w http.ResponseWriter, r *http.Request) error {
+	id, err := getId(r)
+	if err != nil {
+		return err
+	}
+
+	var operations []patchOperation
+	if err := json.NewDecoder(r.Body).Decode(&operations); err != nil {
+		return decodeError(err)
+	}
+
+	p, err := o.db.GetProductById(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return productNotFound(id)
+	}
+	if err != nil {
+		return err
+	}
+
+	request := &UpdateProductRequest{Id: p.Id, Name: p.Name, Code: p.Code, Status: p.Status, updatedAt: p.UpdatedAt}
+	fields := map[string]*string{
+		"/name": &request.Name,
+		"/code": &request.Code,
+	}
+
+	for _, operation := range operations {
+		field, ok := fields[operation.Path]
+		if !ok {
+			return newLocalizedError("patch.unsupported", operation.Op, operation.Path)
+		}
+
+		var value string
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return newLocalizedError("patch.invalidValue", operation.Path)
+		}
+
+		switch operation.Op {
+		case "add", "replace":
+			*field = value
+		case "test":
+			if *field != value {
+				return newAPIError(http.StatusConflict, "patch.testFailed", operation.Path)
+			}
+		default:
+			return newLocalizedError("patch.unsupported", operation.Op, operation.Path)
+		}
+	}
+
+	return o.saveProduct(w, r, request)
+}
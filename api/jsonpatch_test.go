@@ -0,0 +1,55 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"testing"
+)
+
+func TestJSONPatchFailingTestAnswersConflict(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodPut, "/updateProduct/1", `[
+		{"op": "test", "path": "/code", "value": "OTHER-1"},
+		{"op": "replace", "path": "/name", "value": "Table"}
+	]`, "Content-Type", jsonPatchContentType)
+	expectStatus(t, w, http.StatusConflict)
+
+	w = serve(server, http.MethodGet, "/getProduct/1", "")
+	var product getProductResponse
+	decode(t, w, &product)
+	if product.Name != "Desk" {
+		t.Errorf("the product shouldn't have been patched: %+v", product)
+	}
+}
+
+func TestJSONPatchApplies(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodPut, "/updateProduct/1", `[
+		{"op": "test", "path": "/code", "value": "DSK-1"},
+		{"op": "replace", "path": "/name", "value": "Table"}
+	]`, "Content-Type", jsonPatchContentType)
+	expectStatus(t, w, http.StatusOK)
+
+	var body UpdateProductResponse
+	decode(t, w, &body)
+	if body.Name != "Table" || body.Code != "DSK-1" {
+		t.Errorf("unexpected product: %+v", body)
+	}
+}
+
+func TestJSONPatchRejectsTheStatus(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodPut, "/updateProduct/1", `[
+		{"op": "replace", "path": "/status", "value": "inactive"}
+	]`, "Content-Type", jsonPatchContentType)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != `unsupported patch operation "replace" on path "/status"` {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"apiGo/storage"
+	"errors"
+	"net/http"
+)
+
+// activateProduct moves a draft or inactive product to active.
+func (o *Server) activateProduct(w http.ResponseWriter, r *http.Request) error {
+	return o.setProductStatus(w, r, storage.StatusActive)
+}
+
+// deactivateProduct moves an active product to inactive.
+func (o *Server) deactivateProduct(w http.ResponseWriter, r *http.Request) error {
+	return o.setProductStatus(w, r, storage.StatusInactive)
+}
+
+// setProductStatus moves the product identified in the URL to status, answering 409 when its
+// current status can't move to it.
+func (o *Server) setProductStatus(w http.ResponseWriter, r *http.Request, status string) error {
+	id, err := getId(r)
+	if err != nil {
+		return err
+	}
+
+	exists, err := o.db.ProductExists(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return productNotFound(id)
+	}
+
+	if err := o.checkLock(r, id); err != nil {
+		return err
+	}
+
+	p, err := o.db.SetProductStatus(r.Context(), id, status)
+	if errors.Is(err, storage.ErrInvalidTransition) {
+		current, getErr := o.db.GetProductById(r.Context(), id)
+		if getErr != nil {
+			return getErr
+		}
+		return newAPIError(http.StatusConflict, "product.invalidTransition", id, current.Status, status)
+	}
+	if err != nil {
+		return err
+	}
+	o.changes.publish(p)
+
+	response := getProductResponse{
+		Id:        p.Id,
+		Name:      p.Name,
+		Code:      p.Code,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		Status:    p.Status,
+		Views:     p.Views,
+	}
+
+	return writeJSON(w, http.StatusOK, response)
+}
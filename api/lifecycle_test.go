@@ -0,0 +1,65 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"testing"
+)
+
+func TestStatusTransitions(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+	expectStatus(t, serve(server, http.MethodPost, "/createProduct", `{"name":"Desk","code":"DSK-1","status":"draft"}`), http.StatusOK)
+
+	w := serve(server, http.MethodPost, "/getProduct/1/deactivate", "")
+	expectStatus(t, w, http.StatusConflict)
+	var conflict WebError
+	decode(t, w, &conflict)
+	if conflict.Error != "product with ID 1 can't move from draft to inactive" {
+		t.Errorf("unexpected error: %q", conflict.Error)
+	}
+
+	for _, step := range []struct{ action, status string }{{"activate", storage.StatusActive}, {"deactivate", storage.StatusInactive}, {"activate", storage.StatusActive}} {
+		w := serve(server, http.MethodPost, "/getProduct/1/"+step.action, "")
+		expectStatus(t, w, http.StatusOK)
+
+		var body getProductResponse
+		decode(t, w, &body)
+		if body.Status != step.status {
+			t.Errorf("expected %s to move the product to %s, got %s", step.action, step.status, body.Status)
+		}
+	}
+}
+
+func TestInvalidStatusIsRejected(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodPost, "/createProduct", `{"name":"Desk","code":"DSK-1","status":"archived"}`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "status must be one of active, inactive or draft. Given: archived" {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+}
+
+func TestListingsFilterByStatus(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"), storage.NewProduct("Chair", "CHR-1"), storage.NewProduct("Lamp", "LMP-1"))
+	expectStatus(t, serve(server, http.MethodPost, "/getProduct/2/deactivate", ""), http.StatusOK)
+
+	for status, ids := range map[string][]int64{storage.StatusInactive: {2}, storage.StatusActive: {1, 3}} {
+		w := serve(server, http.MethodGet, "/getProducts?status="+status, "")
+		expectStatus(t, w, http.StatusOK)
+
+		var body GetProductsResponse
+		decode(t, w, &body)
+		if len(body.Products) != len(ids) {
+			t.Fatalf("expected products %v with status %s, got %+v", ids, status, body.Products)
+		}
+		for i, id := range ids {
+			if body.Products[i].Id != id {
+				t.Errorf("expected product %d with status %s, got %d", id, status, body.Products[i].Id)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// errResponseTooLarge is returned by writes beyond the configured response size limit.
+var errResponseTooLarge = errors.New("response size limit exceeded")
+
+// limitedResponseWriter is a http.ResponseWriter that refuses to write more than a fixed number of bytes.
+type limitedResponseWriter struct {
+	http.ResponseWriter
+	remaining int64
+	exceeded  bool
+}
+
+// Write writes b while it fits within the limit, and fails once the limit is exceeded.
+func (o *limitedResponseWriter) Write(b []byte) (int, error) {
+	if o.exceeded {
+		return 0, errResponseTooLarge
+	}
+	if int64(len(b)) > o.remaining {
+		o.exceeded = true
+		n, _ := o.ResponseWriter.Write(b[:o.remaining])
+		o.remaining = 0
+		return n, errResponseTooLarge
+	}
+
+	n, err := o.ResponseWriter.Write(b)
+	o.remaining -= int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped writer so http.ResponseController can reach it.
+func (o *limitedResponseWriter) Unwrap() http.ResponseWriter {
+	return o.ResponseWriter
+}
+
+// interceptResponseLimit is a middleware that aborts responses exceeding Config.MaxResponseBytes.
+// The connection is closed so the client can't mistake the truncated body for a complete one.
+func (o *Server) interceptResponseLimit(f http.HandlerFunc) http.HandlerFunc {
+	if o.config.MaxResponseBytes <= 0 {
+		return f
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		lw := &limitedResponseWriter{ResponseWriter: w, remaining: o.config.MaxResponseBytes}
+		f(lw, r)
+		if lw.exceeded {
+			slog.Error("response aborted", "path", r.URL.Path, "limitBytes", o.config.MaxResponseBytes)
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// interceptRequestLimit is a middleware that caps the request body at Config.MaxRequestBytes, so
+// neither the middlewares buffering it nor the decoders read more. Larger bodies are answered with 413.
+func (o *Server) interceptRequestLimit(f apiFunc) apiFunc {
+	if o.config.MaxRequestBytes <= 0 {
+		return f
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		r.Body = http.MaxBytesReader(w, r.Body, o.config.MaxRequestBytes)
+		return f(w, r)
+	}
+}
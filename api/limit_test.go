@@ -0,0 +1,68 @@
+package api
+
+import (
+	"apiGo/storage"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestLimitRejectsLargeBodies(t *testing.T) {
+	body := `{"name":"` + strings.Repeat("a", 2048) + `","code":"CHR-1"}`
+
+	for _, depth := range []int{5, 0} {
+		config := DefaultConfig()
+		config.MaxRequestBytes = 1024
+		config.MaxJSONDepth = depth
+		server, _ := newTestServer(t, config)
+
+		w := serve(server, http.MethodPost, "/createProduct", body)
+		expectStatus(t, w, http.StatusRequestEntityTooLarge)
+
+		var response WebError
+		decode(t, w, &response)
+		if response.Error != "request body is larger than 1024 bytes" {
+			t.Errorf("unexpected error with depth limit %d: %q", depth, response.Error)
+		}
+	}
+}
+
+func TestResponseLimitCutsOffLargeStreams(t *testing.T) {
+	logs := captureLogs(t)
+
+	config := DefaultConfig()
+	config.MaxResponseBytes = 2048
+	config.CompressionAlgorithms = nil
+	server := NewApiServerWithConfig(":0", storage.NewMemStorage(), config)
+	server.handle("GET /export", func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "text/plain")
+		chunk := strings.Repeat("a", 1024)
+		for range 10 {
+			if _, err := io.WriteString(w, chunk); err != nil {
+				return nil
+			}
+			http.NewResponseController(w).Flush()
+		}
+		return nil
+	})
+	httpServer := httptest.NewServer(server.serverMux)
+
+	response, err := http.Get(httpServer.URL + "/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	httpServer.Close()
+	if err == nil {
+		t.Errorf("expected the response to be cut off, got %d bytes", len(body))
+	}
+	if len(body) > 2048 {
+		t.Errorf("expected at most 2048 bytes, got %d", len(body))
+	}
+	if !strings.Contains(logs.String(), `"msg":"response aborted"`) {
+		t.Errorf("expected the abort to be logged, got %s", logs.String())
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Link is a hypermedia link, in the HAL format.
+type Link struct {
+	Href string `json:"href"`
+}
+
+// ProductLinks are the links of a product rendered with links=true.
+type ProductLinks struct {
+	Self   Link `json:"self"`
+	Update Link `json:"update"`
+	Delete Link `json:"delete"`
+}
+
+// productLinks builds the links of the product with the given ID under Config.LinksBasePath, the
+// prefix a reverse proxy may expose the API under.
+func (o *Server) productLinks(id int64) *ProductLinks {
+	base := strings.TrimSuffix(o.config.LinksBasePath, "/")
+	link := func(format string) Link {
+		return Link{Href: base + fmt.Sprintf(format, id)}
+	}
+
+	return &ProductLinks{
+		Self:   link("/getProduct/%d"),
+		Update: link("/updateProduct/%d"),
+		Delete: link("/deleteProduct/%d"),
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"testing"
+)
+
+func TestProductLinksFollowTheBasePath(t *testing.T) {
+	for base, prefix := range map[string]string{"": "", "/catalog/": "/catalog"} {
+		config := DefaultConfig()
+		config.LinksBasePath = base
+		server, _ := newTestServer(t, config, storage.NewProduct("Desk", "DSK-1"))
+
+		w := serve(server, http.MethodGet, "/getProduct/1?links=true", "")
+		expectStatus(t, w, http.StatusOK)
+
+		var body struct {
+			Links *ProductLinks `json:"_links"`
+		}
+		decode(t, w, &body)
+		expected := ProductLinks{
+			Self:   Link{Href: prefix + "/getProduct/1"},
+			Update: Link{Href: prefix + "/updateProduct/1"},
+			Delete: Link{Href: prefix + "/deleteProduct/1"},
+		}
+		if body.Links == nil || *body.Links != expected {
+			t.Errorf("expected the links %+v under %q, got %+v", expected, base, body.Links)
+		}
+	}
+}
+
+func TestProductLinksAreOnlyRenderedWhenAsked(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	var body map[string]any
+	decode(t, serve(server, http.MethodGet, "/getProduct/1", ""), &body)
+	if _, found := body["_links"]; found {
+		t.Errorf("expected no links, got %v", body)
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// qualityValue is an entry of a header such as Accept-Language or Accept-Encoding.
+type qualityValue struct {
+	value   string
+	quality float64
+}
+
+// parseQualityList parses a comma-separated header with optional q weights,
+// returning the entries ordered from most to least preferred. Values are lower-cased.
+func parseQualityList(header string) []qualityValue {
+	values := make([]qualityValue, 0)
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if value == "" {
+			continue
+		}
+		quality := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if v, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = v
+			}
+		}
+		values = append(values, qualityValue{value: strings.ToLower(strings.TrimSpace(value)), quality: quality})
+	}
+
+	slices.SortStableFunc(values, func(a, b qualityValue) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+
+	return values
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"apiGo/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// PartialUpdateProductRequest represents the request structure for patchProduct API.
+// Fields left out, or null, keep their current value.
+type PartialUpdateProductRequest struct {
+	Name   *string `json:"name"`
+	Code   *string `json:"code"`
+	Status *string `json:"status"`
+}
+
+// partialUpdateProduct updates only the fields of the product given in the request body,
+// leaving the others untouched. A body without any field is answered with 400.
+func (o *Server) partialUpdateProduct(w http.ResponseWriter, r *http.Request) error {
+	id, err := getId(r)
+	if err != nil {
+		return err
+	}
+
+	request := new(PartialUpdateProductRequest)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		return decodeError(err)
+	}
+
+	patch := storage.ProductPatch{Name: request.Name, Code: request.Code, Status: request.Status}
+	if patch.Empty() {
+		return newLocalizedError("patch.empty")
+	}
+
+	warnings, err := o.validatePatch(r, patch)
+	if err != nil {
+		return err
+	}
+
+	if err := o.checkLock(r, id); err != nil {
+		return err
+	}
+
+	updatedProduct, err := o.db.PatchProduct(r.Context(), id, patch)
+	if errors.Is(err, storage.ErrNotFound) {
+		return productNotFound(id)
+	}
+	if err != nil {
+		return err
+	}
+	o.changes.publish(updatedProduct)
+
+	response := UpdateProductResponse{
+		Id:        updatedProduct.Id,
+		Name:      updatedProduct.Name,
+		Code:      updatedProduct.Code,
+		CreatedAt: updatedProduct.CreatedAt,
+		UpdatedAt: updatedProduct.UpdatedAt,
+		Status:    updatedProduct.Status,
+		Warnings:  warnings,
+	}
+
+	return writeJSON(w, http.StatusOK, response)
+}
+
+// validatePatch sanitizes and validates the fields given in patch with the rules of updateProduct,
+// returning its warnings.
+func (o *Server) validatePatch(r *http.Request, patch storage.ProductPatch) ([]string, error) {
+	if patch.Name != nil {
+		if err := o.sanitizeField("name", patch.Name); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(*patch.Name) == "" {
+			return nil, newLocalizedError("validation.required", "name")
+		}
+		if err := o.checkLength("name", *patch.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	var warnings []*localizedError
+	if patch.Code != nil {
+		if err := o.sanitizeField("code", patch.Code); err != nil {
+			return nil, err
+		}
+		if err := o.checkCode(*patch.Code); err != nil {
+			return nil, err
+		}
+		if err := o.checkLength("code", *patch.Code); err != nil {
+			return nil, err
+		}
+		warnings = productWarnings(*patch.Code)
+	}
+
+	// Unlike updateProduct, an empty status can't mean "keep the current one" here.
+	if patch.Status != nil && !storage.ValidStatus(*patch.Status) {
+		return nil, newLocalizedError("validation.status", *patch.Status)
+	}
+
+	return o.checkWarnings(r, warnings)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"apiGo/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// problemContentType is the media type of RFC 7807 problem details.
+const problemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the catalog key of an error to form the type of its Problem.
+const problemTypePrefix = "urn:apigo:error:"
+
+// Problem is an error response in the RFC 7807 problem details format.
+type Problem struct {
+	Type     string `json:"type"`     // problemTypePrefix and the catalog key of the error, or about:blank.
+	Title    string `json:"title"`    // Summary of the kind of error, in English.
+	Status   int    `json:"status"`   // HTTP status code of the response.
+	Detail   string `json:"detail"`   // Message of this occurrence, in the language of the request.
+	Instance string `json:"instance"` // Path of the request.
+}
+
+// problemKeyTitles are the titles of the catalog messages of the product errors, more specific than
+// the text of their status.
+var problemKeyTitles = map[string]string{
+	"product.notFound":          "Product Not Found",
+	"product.codeNotFound":      "Product Not Found",
+	"product.codeExists":        "Duplicate Product Code",
+	"product.duplicateCode":     "Duplicate Product Code",
+	"product.locked":            "Product Locked",
+	"product.quotaExceeded":     "Product Quota Exceeded",
+	"product.invalidTransition": "Invalid Status Transition",
+}
+
+// problemTitles are the titles of the storage errors answered without a catalog message of their own.
+var problemTitles = []struct {
+	err   error
+	title string
+}{
+	{storage.ErrNotFound, "Product Not Found"},
+	{storage.ErrDuplicateCode, "Duplicate Product Code"},
+	{storage.ErrInvalidData, "Invalid Product Data"},
+	{storage.ErrNotInitialized, "Service Not Initialized"},
+	{storage.ErrQuotaExceeded, "Product Quota Exceeded"},
+	{storage.ErrProductLocked, "Product Locked"},
+	{storage.ErrInvalidTransition, "Invalid Status Transition"},
+}
+
+// wantsProblem reports whether the errors of r are rendered as Problem: always with Config.ProblemDetails,
+// otherwise when the Accept header prefers application/problem+json to application/json.
+func (o *Server) wantsProblem(r *http.Request) bool {
+	if o.config.ProblemDetails {
+		return true
+	}
+	for _, accepted := range parseQualityList(r.Header.Get("Accept")) {
+		if accepted.quality <= 0 {
+			continue
+		}
+		switch accepted.value {
+		case problemContentType:
+			return true
+		case "application/json", "application/*", "*/*":
+			return false
+		}
+	}
+	return false
+}
+
+// writeError answers err with the status code picked by errorStatus, as a WebError or, when the client
+// wants it, as a Problem.
+func (o *Server) writeError(w http.ResponseWriter, r *http.Request, err error) error {
+	status, shown := errorStatus(err)
+	w.Header().Set("Content-Language", requestLanguage(r))
+	if !o.wantsProblem(r) {
+		return writeJSON(w, status, WebError{Error: errorMessage(r, shown)})
+	}
+
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   errorMessage(r, shown),
+		Instance: r.URL.Path,
+	}
+	var localized *localizedError
+	if errors.As(shown, &localized) {
+		problem.Type = problemTypePrefix + localized.key
+		if title, ok := problemKeyTitles[localized.key]; ok {
+			problem.Title = title
+		}
+	}
+	for _, known := range problemTitles {
+		if errors.Is(err, known.err) {
+			problem.Title = known.title
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(problem)
+}
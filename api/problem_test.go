@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProblemDetailsOfNotFound(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodGet, "/getProduct/9", "", "Accept", "application/problem+json, application/json;q=0.5")
+	expectStatus(t, w, http.StatusNotFound)
+	if got := w.Header().Get("Content-Type"); got != problemContentType {
+		t.Errorf("unexpected content type %q", got)
+	}
+
+	var problem Problem
+	decode(t, w, &problem)
+	expected := Problem{
+		Type:     problemTypePrefix + "product.notFound",
+		Title:    "Product Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "product with ID 9 not found",
+		Instance: "/getProduct/9",
+	}
+	if problem != expected {
+		t.Errorf("expected %+v, got %+v", expected, problem)
+	}
+}
+
+func TestProblemDetailsOfValidationError(t *testing.T) {
+	config := DefaultConfig()
+	config.ProblemDetails = true
+	server, _ := newTestServer(t, config)
+
+	w := serve(server, http.MethodPost, "/createProduct", `{"name": "", "code": "DSK-1"}`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	var problem Problem
+	decode(t, w, &problem)
+	expected := Problem{
+		Type:     problemTypePrefix + "validation.required",
+		Title:    "Bad Request",
+		Status:   http.StatusBadRequest,
+		Detail:   "name is required",
+		Instance: "/createProduct",
+	}
+	if problem != expected {
+		t.Errorf("expected %+v, got %+v", expected, problem)
+	}
+}
+
+func TestErrorsAreWebErrorsByDefault(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodGet, "/getProduct/9", "", "Accept", "application/json")
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("unexpected content type %q", got)
+	}
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"apiGo/storage"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a loopback TCP address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	return addr
+}
+
+// startServer runs server until the returned cancel is called, once it accepts connections on addr.
+// The channel receives what RunContext returns.
+func startServer(t *testing.T, server *Server, network, addr string) (context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() {
+		ran <- server.RunContext(ctx)
+	}()
+	t.Cleanup(cancel)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial(network, addr)
+		if err == nil {
+			_ = conn.Close()
+			return cancel, ran
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the server didn't listen on %s: %v", addr, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitRun waits for RunContext to return and returns its error.
+func waitRun(t *testing.T, ran <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-ran:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext didn't return")
+		return nil
+	}
+}
+
+func TestRunListensOnTheListenAddress(t *testing.T) {
+	addr := freeAddr(t)
+	server := NewApiServerWithConfig(addr, storage.NewMemStorage(), DefaultConfig())
+	server.HandleEndpoints()
+	cancel, ran := startServer(t, server, "tcp", addr)
+
+	response, err := http.Get("http://" + addr + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", response.StatusCode)
+	}
+
+	cancel()
+	if err := waitRun(t, ran); err != nil {
+		t.Errorf("expected RunContext to stop cleanly, got %v", err)
+	}
+}
+
+func TestRunReportsTheAddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	addr := listener.Addr().String()
+	server := NewApiServerWithConfig(addr, storage.NewMemStorage(), DefaultConfig())
+	err = server.RunContext(context.Background())
+	if !errors.Is(err, ErrAddressInUse) {
+		t.Fatalf("expected ErrAddressInUse, got %v", err)
+	}
+	if !strings.Contains(err.Error(), addr) {
+		t.Errorf("expected the error to name %s, got %q", addr, err)
+	}
+}
+
+// slowServer creates a server on addr with a GET /slow route that answers once release is closed.
+// started receives a value once a request reaches the handler.
+func slowServer(addr string, config Config) (server *Server, started chan struct{}, release chan struct{}) {
+	server = NewApiServerWithConfig(addr, storage.NewMemStorage(), config)
+	started = make(chan struct{}, 1)
+	release = make(chan struct{})
+	server.handle("GET /slow", func(w http.ResponseWriter, r *http.Request) error {
+		started <- struct{}{}
+		<-release
+		return writeJSON(w, http.StatusOK, map[string]string{"status": "done"})
+	})
+	return server, started, release
+}
+
+func TestShutdownLetsInFlightRequestsFinish(t *testing.T) {
+	addr := freeAddr(t)
+	server, started, release := slowServer(addr, DefaultConfig())
+	cancel, ran := startServer(t, server, "tcp", addr)
+
+	answered := make(chan int, 1)
+	go func() {
+		response, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			answered <- 0
+			return
+		}
+		_ = response.Body.Close()
+		answered <- response.StatusCode
+	}()
+	<-started
+
+	cancel()
+	select {
+	case err := <-ran:
+		t.Fatalf("RunContext returned before the in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if status := <-answered; status != http.StatusOK {
+		t.Errorf("expected the in-flight request to be answered 200, got %d", status)
+	}
+	if err := waitRun(t, ran); err != nil {
+		t.Errorf("expected RunContext to stop cleanly, got %v", err)
+	}
+}
+
+func TestShutdownCutsOffRequestsAfterTheTimeout(t *testing.T) {
+	addr := freeAddr(t)
+	config := DefaultConfig()
+	config.ShutdownTimeout = 50 * time.Millisecond
+	server, started, release := slowServer(addr, config)
+	defer close(release)
+	cancel, ran := startServer(t, server, "tcp", addr)
+
+	go func() {
+		if response, err := http.Get("http://" + addr + "/slow"); err == nil {
+			_ = response.Body.Close()
+		}
+	}()
+	<-started
+
+	cancel()
+	if err := waitRun(t, ran); !errors.Is(err, ErrShutdownTimeout) {
+		t.Errorf("expected ErrShutdownTimeout, got %v", err)
+	}
+}
+
+func TestRunReturnsRightAwayAfterShutdown(t *testing.T) {
+	server := NewApiServerWithConfig(freeAddr(t), storage.NewMemStorage(), DefaultConfig())
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("expected Shutdown before Run to succeed, got %v", err)
+	}
+
+	ran := make(chan error, 1)
+	go func() {
+		ran <- server.RunContext(context.Background())
+	}()
+	if err := waitRun(t, ran); err != nil {
+		t.Errorf("expected RunContext to return cleanly, got %v", err)
+	}
+}
+
+func TestRunTwiceReturnsErrAlreadyStarted(t *testing.T) {
+	addr := freeAddr(t)
+	server := NewApiServerWithConfig(addr, storage.NewMemStorage(), DefaultConfig())
+	cancel, ran := startServer(t, server, "tcp", addr)
+
+	if err := server.RunContext(context.Background()); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("expected ErrAlreadyStarted, got %v", err)
+	}
+
+	cancel()
+	if err := waitRun(t, ran); err != nil {
+		t.Errorf("expected the first RunContext to stop cleanly, got %v", err)
+	}
+}
+
+// unixClient is an HTTP client sending every request over the Unix socket at path.
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestRunServesOverAUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	server := NewApiServerWithConfig(unixAddrPrefix+path, storage.NewMemStorage(), DefaultConfig())
+	server.HandleEndpoints()
+	cancel, ran := startServer(t, server, "unix", path)
+
+	response, err := unixClient(path).Get("http://api/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", response.StatusCode)
+	}
+
+	cancel()
+	if err := waitRun(t, ran); err != nil {
+		t.Fatalf("expected RunContext to stop cleanly, got %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the socket file to be removed, got %v", err)
+	}
+}
+
+func TestRunReplacesAStaleUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Left behind as by a crashed process: the file stays but nothing answers on it.
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	server := NewApiServerWithConfig(unixAddrPrefix+path, storage.NewMemStorage(), DefaultConfig())
+	cancel, ran := startServer(t, server, "unix", path)
+	cancel()
+	if err := waitRun(t, ran); err != nil {
+		t.Errorf("expected RunContext to stop cleanly, got %v", err)
+	}
+}
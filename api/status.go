@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// healthCheckTimeout bounds the database ping of a health check, below the usual probe timeouts.
+const healthCheckTimeout = 2 * time.Second
+
+// Statuses answered by the health endpoint.
+const (
+	HealthOk          = "ok"
+	HealthUnavailable = "unavailable"
+)
+
+// StatusResponse represents the response structure for status API.
+type StatusResponse struct {
+	UptimeSeconds int64     `json:"uptimeSeconds"`
+	TotalRequests int64     `json:"totalRequests"`
+	StartedAt     time.Time `json:"startedAt"`
+
+	Admission *AdmissionStats `json:"admission,omitempty"` // Only when MAX_CONCURRENT_REQUESTS is set.
+}
+
+// HealthResponse represents the response structure for health API.
+type HealthResponse struct {
+	Status string `json:"status"` // HealthOk or HealthUnavailable.
+}
+
+// interceptCount is a middleware that counts the requests served, for the status endpoint.
+func (o *Server) interceptCount(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		o.requests.Add(1)
+		f(w, r)
+	}
+}
+
+// getStatus reports how long the server has been running and how many requests it has served.
+// It doesn't touch the database, so it's cheap enough for a status page to poll.
+func (o *Server) getStatus(w http.ResponseWriter, _ *http.Request) error {
+	response := StatusResponse{
+		UptimeSeconds: int64(time.Since(o.startedAt).Seconds()),
+		TotalRequests: o.requests.Load(),
+		StartedAt:     o.startedAt,
+	}
+	if o.admission != nil {
+		response.Admission = o.admission.stats()
+	}
+
+	return writeJSON(w, http.StatusOK, response)
+}
+
+// getHealth reports whether the database can be reached, for load balancer probes. The outcome is
+// always written here rather than returned, so a down database answers 503 with the probe's body
+// instead of the error of the product endpoints.
+func (o *Server) getHealth(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	if err := o.db.Ping(ctx); err != nil {
+		slog.Warn("health check failed", "error", err.Error())
+		return writeJSON(w, http.StatusServiceUnavailable, HealthResponse{Status: HealthUnavailable})
+	}
+
+	return writeJSON(w, http.StatusOK, HealthResponse{Status: HealthOk})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"apiGo/storage"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStatusReportsUptimeAndRequests(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+	server.startedAt = time.Now().UTC().Add(-time.Minute)
+
+	serve(server, http.MethodGet, "/getProducts", "")
+	serve(server, http.MethodGet, "/getProduct/9", "")
+
+	w := serve(server, http.MethodGet, "/status", "")
+	expectStatus(t, w, http.StatusOK)
+
+	var body StatusResponse
+	decode(t, w, &body)
+	if body.UptimeSeconds < 60 {
+		t.Errorf("expected an uptime of at least 60s, got %d", body.UptimeSeconds)
+	}
+	if !body.StartedAt.Equal(server.startedAt) {
+		t.Errorf("expected startedAt %s, got %s", server.startedAt, body.StartedAt)
+	}
+	// The status request itself is counted too.
+	if body.TotalRequests != 3 {
+		t.Errorf("expected 3 requests, got %d", body.TotalRequests)
+	}
+
+	w = serve(server, http.MethodGet, "/status", "")
+	decode(t, w, &body)
+	if body.TotalRequests != 4 {
+		t.Errorf("expected 4 requests, got %d", body.TotalRequests)
+	}
+}
+
+// downStorage is a MemStorage whose database can't be reached.
+type downStorage struct {
+	*storage.MemStorage
+}
+
+func (o *downStorage) Ping(context.Context) error {
+	return errors.New("dial tcp 127.0.0.1:5439: connect: connection refused")
+}
+
+func TestHealthReportsTheDatabase(t *testing.T) {
+	tests := []struct {
+		db     storage.Storage
+		status int
+		body   string
+	}{
+		{storage.NewMemStorage(), http.StatusOK, HealthOk},
+		{&downStorage{MemStorage: storage.NewMemStorage()}, http.StatusServiceUnavailable, HealthUnavailable},
+	}
+
+	for _, test := range tests {
+		server := NewApiServerWithConfig(":0", test.db, DefaultConfig())
+		server.HandleEndpoints()
+
+		w := serve(server, http.MethodGet, "/health", "")
+		expectStatus(t, w, test.status)
+
+		var body HealthResponse
+		decode(t, w, &body)
+		if body.Status != test.body {
+			t.Errorf("expected the status %q, got %q", test.body, body.Status)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"apiGo/storage"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// acquireStream reserves one of the Config.MaxConcurrentStreams streaming responses. It returns the
+// function releasing it, or an error answered with 503 when they're all taken. Zero disables the limit.
+func (o *Server) acquireStream() (func(), error) {
+	if o.config.MaxConcurrentStreams <= 0 {
+		return func() {}, nil
+	}
+	if o.streams.Add(1) > int64(o.config.MaxConcurrentStreams) {
+		o.streams.Add(-1)
+		return nil, newAPIError(http.StatusServiceUnavailable, "stream.tooMany", o.config.MaxConcurrentStreams)
+	}
+	return func() { o.streams.Add(-1) }, nil
+}
+
+// totalCountHeader carries the number of products matching a listing across all pages.
+const totalCountHeader = "X-Total-Count"
+
+// writeProductsPage writes a GetProductsPageResponse one product at a time, so the document is never
+// buffered as a whole. The total is sent in the X-Total-Count header too, before the body.
+//
+// Products are written as CompactProduct when omitEmpty is set.
+//
+// Once the body has started the status can't change anymore, so a failing write is logged and the
+// response abandoned rather than answered with an error. See bufferProductsPage for the alternative.
+func writeProductsPage(w http.ResponseWriter, products []*storage.Product, total int64, page storage.Page, omitEmpty bool) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(totalCountHeader, strconv.FormatInt(total, 10))
+	w.WriteHeader(http.StatusOK)
+
+	write := func(data []byte) bool {
+		if _, err := w.Write(data); err != nil {
+			slog.Error("products couldn't be written, abandoning the response", "error", err.Error())
+			return false
+		}
+		return true
+	}
+
+	if !write([]byte(`{"products":[`)) {
+		return nil
+	}
+	for i, p := range products {
+		var v any = p
+		if omitEmpty {
+			v = compactProduct(p)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			slog.Error("product couldn't be encoded, abandoning the response", "id", p.Id, "error", err.Error())
+			return nil
+		}
+		if i > 0 {
+			data = append([]byte{','}, data...)
+		}
+		if !write(data) {
+			return nil
+		}
+	}
+	write([]byte(fmt.Sprintf(`],"total":%d,"limit":%d,"offset":%d}`+"\n", total, page.Limit, page.Offset)))
+
+	return nil
+}
+
+// bufferProductsPage writes the same document as writeProductsPage, encoded as a whole like the other
+// responses, so an encoding failure is still answered with an error. Used when streaming is disabled.
+func bufferProductsPage(w http.ResponseWriter, products []*storage.Product, total int64, page storage.Page, omitEmpty bool) error {
+	items := make([]any, len(products))
+	for i, p := range products {
+		items[i] = p
+		if omitEmpty {
+			items[i] = compactProduct(p)
+		}
+	}
+
+	w.Header().Set(totalCountHeader, strconv.FormatInt(total, 10))
+	return writeJSON(w, http.StatusOK, struct {
+		Products []any `json:"products"`
+		Total    int64 `json:"total"`
+		Limit    int   `json:"limit"`
+		Offset   int   `json:"offset"`
+	}{items, total, page.Limit, page.Offset})
+}
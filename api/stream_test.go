@@ -0,0 +1,77 @@
+package api
+
+import (
+	"apiGo/storage"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListingsDontTakeStreams(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConcurrentStreams = 1
+	server, _ := newTestServer(t, config, storage.NewProduct("Desk", "DSK-1"))
+
+	release, err := server.acquireStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	w := serve(server, http.MethodGet, "/getProducts", "")
+	expectStatus(t, w, http.StatusOK)
+
+	w = serve(server, http.MethodGet, "/changes/longpoll?timeout=1ms", "")
+	expectStatus(t, w, http.StatusServiceUnavailable)
+}
+
+// brokenClientWriter is a ResponseWriter whose writes fail once the client went away after ok writes.
+type brokenClientWriter struct {
+	*httptest.ResponseRecorder
+	ok     int
+	writes int
+}
+
+func (o *brokenClientWriter) Write(b []byte) (int, error) {
+	o.writes++
+	if o.writes > o.ok {
+		return 0, errors.New("write tcp: i/o timeout")
+	}
+	return o.ResponseRecorder.Write(b)
+}
+
+func TestStreamedPageIsAbandonedOnceTheClientIsGone(t *testing.T) {
+	logs := captureLogs(t)
+
+	w := &brokenClientWriter{ResponseRecorder: httptest.NewRecorder(), ok: 2}
+	if err := writeProductsPage(w, manyProducts(), 50, storage.Page{Limit: 50}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.writes != 3 {
+		t.Errorf("expected the writes to stop at the first failure, got %d writes", w.writes)
+	}
+	if !strings.Contains(logs.String(), "abandoning the response") {
+		t.Errorf("expected the abort to be logged, got %s", logs.String())
+	}
+}
+
+func TestStreamedListingSendsTheTotalHeader(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), manyProducts()[:5]...)
+
+	for target, count := range map[string]int{"/getProducts": 5, "/getProducts?limit=2": 2} {
+		w := serve(server, http.MethodGet, target, "")
+		expectStatus(t, w, http.StatusOK)
+
+		var body GetProductsPageResponse
+		decode(t, w, &body)
+		if len(body.Products) != count {
+			t.Errorf("expected %d products from %s, got %d", count, target, len(body.Products))
+		}
+		if got := w.Header().Get(totalCountHeader); got != "5" || body.Total != 5 {
+			t.Errorf("expected a total of 5 from %s, got the header %q and %d", target, got, body.Total)
+		}
+	}
+}
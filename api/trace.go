@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the HTTP layer.
+var tracer = otel.Tracer("apiGo/api")
+
+// meter creates the metrics of the HTTP layer.
+var meter = otel.Meter("apiGo/api")
+
+// Histograms of the sizes of the request and response bodies, by endpoint. The global meter is
+// a no-op until telemetry.Setup installs a provider, which they are then bound to.
+var (
+	requestBodySize, _ = meter.Int64Histogram("http.server.request.body.size",
+		metric.WithUnit("By"), metric.WithDescription("Size of the request bodies, as read by the handlers."))
+	responseBodySize, _ = meter.Int64Histogram("http.server.response.body.size",
+		metric.WithUnit("By"), metric.WithDescription("Size of the response bodies, as written on the wire."))
+)
+
+// statusRecorder is a http.ResponseWriter remembering the status code and the number of body bytes
+// sent to the client.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// Write counts the body bytes before sending them.
+func (o *statusRecorder) Write(b []byte) (int, error) {
+	n, err := o.ResponseWriter.Write(b)
+	o.bytes += int64(n)
+	return n, err
+}
+
+// countingReader is a request body counting the bytes read from it.
+type countingReader struct {
+	io.ReadCloser
+	bytes int64
+}
+
+// Read counts the bytes read.
+func (o *countingReader) Read(b []byte) (int, error) {
+	n, err := o.ReadCloser.Read(b)
+	o.bytes += int64(n)
+	return n, err
+}
+
+// WriteHeader records the status code before sending it.
+func (o *statusRecorder) WriteHeader(status int) {
+	o.status = status
+	o.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the wrapped writer so http.ResponseController can reach it.
+func (o *statusRecorder) Unwrap() http.ResponseWriter {
+	return o.ResponseWriter
+}
+
+// interceptTrace is a middleware that runs the request inside a server span, continuing the trace
+// propagated by the client if any. The span is a no-op unless tracing is enabled. The sizes of the
+// request and response bodies are recorded on it and in the body size histograms, labeled by endpoint.
+func interceptTrace(pattern string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", r.Method, pattern),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", pattern),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		f(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", recorder.status),
+			attribute.Int64("http.request.body.size", body.bytes),
+			attribute.Int64("http.response.body.size", recorder.bytes),
+		)
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+
+		endpoint := metric.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", pattern),
+		)
+		requestBodySize.Record(ctx, body.bytes, endpoint)
+		responseBodySize.Record(ctx, recorder.bytes, endpoint)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// metricReader collects the metrics of the tests. The instruments of the package are bound to the
+// first global provider set, so every test, and every run of it, shares this one.
+var metricReader = func() *sdkmetric.ManualReader {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	return reader
+}()
+
+// histogramPoint returns the count and sum recorded so far by the named histogram for route.
+func histogramPoint(t *testing.T, name, route string) (uint64, int64) {
+	t.Helper()
+
+	var metrics metricdata.ResourceMetrics
+	if err := metricReader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			histogram, ok := m.Data.(metricdata.Histogram[int64])
+			if m.Name != name || !ok {
+				continue
+			}
+			for _, point := range histogram.DataPoints {
+				if value, _ := point.Attributes.Value("http.route"); value == attribute.StringValue(route) {
+					return point.Count, point.Sum
+				}
+			}
+		}
+	}
+	return 0, 0
+}
+
+func TestBodySizesAreRecordedByEndpoint(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig())
+	requests, requestBytes := histogramPoint(t, "http.server.request.body.size", "/createProduct")
+	responses, responseBytes := histogramPoint(t, "http.server.response.body.size", "/createProduct")
+
+	body := `{"name": "Desk", "code": "DSK-1"}`
+	w := serve(server, http.MethodPost, "/createProduct", body)
+	expectStatus(t, w, http.StatusOK)
+
+	count, sum := histogramPoint(t, "http.server.request.body.size", "/createProduct")
+	if count != requests+1 || sum != requestBytes+int64(len(body)) {
+		t.Errorf("expected a request of %d bytes to be recorded, got %d requests of %d bytes", len(body), count-requests, sum-requestBytes)
+	}
+	count, sum = histogramPoint(t, "http.server.response.body.size", "/createProduct")
+	if count != responses+1 || sum != responseBytes+int64(w.Body.Len()) {
+		t.Errorf("expected a response of %d bytes to be recorded, got %d responses of %d bytes", w.Body.Len(), count-responses, sum-responseBytes)
+	}
+}
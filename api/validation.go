@@ -0,0 +1,195 @@
+package api
+
+import (
+	"apiGo/storage"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFieldLength is the size of the name and code columns.
+const maxFieldLength = 50
+
+// Handling of control characters in product fields, selected with Config.ControlCharacters.
+const (
+	ControlCharactersReject = "reject" // Answer 400 naming the field.
+	ControlCharactersStrip  = "strip"  // Remove them silently.
+)
+
+// recommendedCodePattern is the advisory format of product codes, such as ABC-123.
+var recommendedCodePattern = regexp.MustCompile(`^[A-Z0-9]+(-[A-Z0-9]+)*$`)
+
+// productWarnings checks the advisory rules of a product code. Breaking them doesn't prevent
+// the product from being persisted unless Config.WarningsAsErrors is set.
+func productWarnings(code string) []*localizedError {
+	warnings := make([]*localizedError, 0)
+	if code != "" && !recommendedCodePattern.MatchString(code) {
+		warnings = append(warnings, &localizedError{key: "warning.codePattern", args: []any{code}})
+	}
+	return warnings
+}
+
+// checkCode rejects an empty code unless Config.CodeRequired is off, or Config.GenerateCodes fills it in.
+func (o *Server) checkCode(code string) error {
+	if o.config.CodeRequired && !o.config.GenerateCodes && strings.TrimSpace(code) == "" {
+		return newLocalizedError("validation.required", "code")
+	}
+	return nil
+}
+
+// checkLength rejects a product field longer than maxFieldLength, counted in the unit of Config.LengthUnit.
+func (o *Server) checkLength(name, value string) error {
+	if o.config.LengthUnit == storage.LengthUnitBytes {
+		if n := len(value); n > maxFieldLength {
+			return newLocalizedError("validation.tooLongBytes", name, maxFieldLength, n)
+		}
+		return nil
+	}
+
+	if n := utf8.RuneCountInString(value); n > maxFieldLength {
+		return newLocalizedError("validation.tooLongRunes", name, maxFieldLength, n)
+	}
+	return nil
+}
+
+// checkLengths applies checkLength to the name and code of a product.
+func (o *Server) checkLengths(name, code string) error {
+	if err := o.checkLength("name", name); err != nil {
+		return err
+	}
+	return o.checkLength("code", code)
+}
+
+// checkCreatedAt rejects a creation time given by the client further in the future than
+// Config.MaxCreatedAtSkew, which tolerates clocks slightly ahead of the server's.
+func (o *Server) checkCreatedAt(createdAt *time.Time) error {
+	if createdAt == nil {
+		return nil
+	}
+	if limit := time.Now().Add(o.config.MaxCreatedAtSkew); createdAt.After(limit) {
+		return newLocalizedError("validation.createdAtFuture", createdAt.UTC().Format(time.RFC3339), o.config.MaxCreatedAtSkew)
+	}
+	return nil
+}
+
+// checkStatus rejects a status outside of the product lifecycle. An empty status is accepted.
+func checkStatus(status string) error {
+	if status != "" && !storage.ValidStatus(status) {
+		return newLocalizedError("validation.status", status)
+	}
+	return nil
+}
+
+// Validate checks the rules of a product to be created that don't depend on the configuration.
+// Code and length rules, which do, are checked by the server.
+func (o *CreateProductRequest) Validate() error {
+	return validateProductFields(o.Name, o.Status)
+}
+
+// Validate checks the rules of a product update that don't depend on the configuration.
+func (o *UpdateProductRequest) Validate() error {
+	return validateProductFields(o.Name, o.Status)
+}
+
+// validateProductFields rejects a blank name and an unknown status.
+func validateProductFields(name, status string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	return checkStatus(status)
+}
+
+// checkName rejects a blank name.
+func checkName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return newLocalizedError("validation.required", "name")
+	}
+	return nil
+}
+
+// validateCreateRequest sanitizes and validates a product to be created, returning its warnings
+// or the first rule it breaks.
+func (o *Server) validateCreateRequest(r *http.Request, request *CreateProductRequest) ([]string, error) {
+	warnings, errs := o.creationErrors(r, request)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return warnings, nil
+}
+
+// creationErrors sanitizes and validates a product to be created, returning its warnings and every rule
+// it breaks, so bulk requests can report them all at once.
+func (o *Server) creationErrors(r *http.Request, request *CreateProductRequest) ([]string, []error) {
+	checks := []error{
+		o.sanitizeField("name", &request.Name),
+		o.sanitizeField("code", &request.Code),
+	}
+	// Validated once sanitized, so stripped control characters can't leave a blank name behind.
+	checks = append(checks,
+		checkName(request.Name),
+		checkStatus(request.Status),
+		o.checkCode(request.Code),
+		o.checkLength("name", request.Name),
+		o.checkLength("code", request.Code),
+		o.checkCreatedAt(request.CreatedAt),
+	)
+	warnings, err := o.checkWarnings(r, productWarnings(request.Code))
+	checks = append(checks, err)
+
+	errs := make([]error, 0)
+	for _, err := range checks {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return warnings, errs
+}
+
+// checkWarnings returns the first warning as an error when warnings escalate to errors,
+// otherwise the warnings rendered in the language of the request.
+func (o *Server) checkWarnings(r *http.Request, warnings []*localizedError) ([]string, error) {
+	if len(warnings) > 0 && o.config.WarningsAsErrors {
+		return nil, warnings[0]
+	}
+
+	messages := make([]string, len(warnings))
+	for i, warning := range warnings {
+		messages[i] = errorMessage(r, warning)
+	}
+	return messages, nil
+}
+
+// sanitizeField applies Config.ControlCharacters to the named product field.
+// Spaces and tabs are normal whitespace and are always kept.
+func (o *Server) sanitizeField(name string, value *string) error {
+	if !strings.ContainsFunc(*value, isControlCharacter) {
+		return nil
+	}
+	if o.config.ControlCharacters == ControlCharactersReject {
+		return newLocalizedError("validation.controlCharacters", name)
+	}
+
+	*value = strings.Map(func(r rune) rune {
+		if isControlCharacter(r) {
+			return -1
+		}
+		return r
+	}, *value)
+	return nil
+}
+
+// sanitizeProduct applies sanitizeField to the name and code of a product.
+func (o *Server) sanitizeProduct(name, code *string) error {
+	if err := o.sanitizeField("name", name); err != nil {
+		return err
+	}
+	return o.sanitizeField("code", code)
+}
+
+// isControlCharacter reports whether r is a control character other than a tab.
+func isControlCharacter(r rune) bool {
+	return unicode.IsControl(r) && r != '\t'
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"apiGo/storage"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodeOutsideTheRecommendedFormatPersistsWithAWarning(t *testing.T) {
+	server, db := newTestServer(t, DefaultConfig())
+
+	w := serve(server, http.MethodPost, "/createProduct", `{"name":"Desk","code":"dsk_1"}`)
+	expectStatus(t, w, http.StatusOK)
+
+	var body CreateProductResponse
+	decode(t, w, &body)
+	if len(body.Warnings) != 1 {
+		t.Fatalf("expected a warning, got %v", body.Warnings)
+	}
+	if _, err := db.GetProductById(context.Background(), body.Id); err != nil {
+		t.Errorf("expected the product to be persisted: %v", err)
+	}
+}
+
+func TestWarningsAsErrorsRejectsTheProduct(t *testing.T) {
+	config := DefaultConfig()
+	config.WarningsAsErrors = true
+	server, db := newTestServer(t, config)
+
+	w := serve(server, http.MethodPost, "/createProduct", `{"name":"Desk","code":"dsk_1"}`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	if _, err := db.GetProductByCode(context.Background(), "dsk_1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the product not to be persisted, got %v", err)
+	}
+}
+
+func TestControlCharactersAreRejectedOrStripped(t *testing.T) {
+	names := []struct{ json, stripped string }{
+		{`Desk\u0000`, "Desk"},
+		{`Desk\nTop`, "DeskTop"},
+	}
+
+	for _, name := range names {
+		t.Run(ControlCharactersReject+" "+name.json, func(t *testing.T) {
+			server, _ := newTestServer(t, DefaultConfig())
+
+			w := serve(server, http.MethodPost, "/createProduct", `{"name":"`+name.json+`","code":"DSK-1"}`)
+			expectStatus(t, w, http.StatusBadRequest)
+
+			var body WebError
+			decode(t, w, &body)
+			if body.Error != "name must not contain control characters such as line breaks or NUL" {
+				t.Errorf("unexpected error: %q", body.Error)
+			}
+		})
+
+		t.Run(ControlCharactersStrip+" "+name.json, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ControlCharacters = ControlCharactersStrip
+			server, db := newTestServer(t, config)
+
+			w := serve(server, http.MethodPost, "/createProduct", `{"name":"`+name.json+`","code":"DSK-1"}`)
+			expectStatus(t, w, http.StatusOK)
+
+			product, err := db.GetProductByCode(context.Background(), "DSK-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if product.Name != name.stripped {
+				t.Errorf("expected the name %q, got %q", name.stripped, product.Name)
+			}
+		})
+	}
+}
+
+func TestCodeRequiredRejectsCodelessProducts(t *testing.T) {
+	server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+	for _, request := range []struct{ method, target string }{{http.MethodPost, "/createProduct"}, {http.MethodPut, "/updateProduct/1"}} {
+		w := serve(server, request.method, request.target, `{"name":"Chair","code":""}`)
+		expectStatus(t, w, http.StatusBadRequest)
+
+		var body WebError
+		decode(t, w, &body)
+		if body.Error != "code is required" {
+			t.Errorf("unexpected error of %s: %q", request.target, body.Error)
+		}
+	}
+}
+
+func TestCodeOptionalAllowsCodelessProducts(t *testing.T) {
+	config := DefaultConfig()
+	config.CodeRequired = false
+	server, db := newTestServer(t, config, storage.NewProduct("Desk", "DSK-1"))
+
+	w := serve(server, http.MethodPost, "/createProduct", `{"name":"Chair"}`)
+	expectStatus(t, w, http.StatusOK)
+	w = serve(server, http.MethodPut, "/updateProduct/1", `{"name":"Desk","code":""}`)
+	expectStatus(t, w, http.StatusOK)
+
+	for _, id := range []int64{1, 2} {
+		product, err := db.GetProductById(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if product.Code != "" {
+			t.Errorf("expected product %d to have no code, got %q", id, product.Code)
+		}
+	}
+}
+
+func TestLengthLimitCountsTheConfiguredUnit(t *testing.T) {
+	name := strings.Repeat("机", 30) // 30 characters, 90 bytes once UTF-8 encoded.
+
+	server, _ := newTestServer(t, DefaultConfig())
+	expectStatus(t, serve(server, http.MethodPost, "/createProduct", `{"name":"`+name+`","code":"DSK-1"}`), http.StatusOK)
+
+	config := DefaultConfig()
+	config.LengthUnit = storage.LengthUnitBytes
+	server, _ = newTestServer(t, config)
+	w := serve(server, http.MethodPost, "/createProduct", `{"name":"`+name+`","code":"DSK-1"}`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "name must be at most 50 bytes once UTF-8 encoded. Given: 90 bytes" {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+}
+
+// generatingStorage is a MemStorage filling in empty codes as the PgStorage trigger of CODE_AUTO_GENERATE does.
+type generatingStorage struct {
+	*storage.MemStorage
+}
+
+func (o *generatingStorage) CreateProduct(ctx context.Context, p *storage.Product) (*storage.Product, error) {
+	created, err := o.MemStorage.CreateProduct(ctx, p)
+	if err != nil || created.Code != "" {
+		return created, err
+	}
+	code := fmt.Sprintf("PRD-%08d", created.Id)
+	return o.MemStorage.PatchProduct(ctx, created.Id, storage.ProductPatch{Code: &code})
+}
+
+func TestGeneratedCodesFillInOnlyMissingCodes(t *testing.T) {
+	config := DefaultConfig()
+	config.GenerateCodes = true
+	server := NewApiServerWithConfig(":0", &generatingStorage{MemStorage: storage.NewMemStorage()}, config)
+	server.HandleEndpoints()
+
+	tests := []struct{ body, code string }{
+		{`{"name":"Desk"}`, "PRD-00000001"},
+		{`{"name":"Chair","code":"CHR-1"}`, "CHR-1"},
+	}
+
+	for _, test := range tests {
+		w := serve(server, http.MethodPost, "/createProduct", test.body)
+		expectStatus(t, w, http.StatusOK)
+
+		var response CreateProductResponse
+		decode(t, w, &response)
+		if response.Code != test.code {
+			t.Errorf("expected the code %q for %s, got %q", test.code, test.body, response.Code)
+		}
+	}
+}
+
+func TestCreatedAtMayNotBeTooFarInTheFuture(t *testing.T) {
+	server, db := newTestServer(t, DefaultConfig())
+
+	soon := time.Now().UTC().Add(time.Minute).Truncate(time.Second)
+	w := serve(server, http.MethodPost, "/createProduct", `{"name":"Desk","code":"DSK-1","createdAt":"`+soon.Format(time.RFC3339)+`"}`)
+	expectStatus(t, w, http.StatusOK)
+	product, err := db.GetProductByCode(context.Background(), "DSK-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !product.CreatedAt.Equal(soon) {
+		t.Errorf("expected the given createdAt %s, got %s", soon, product.CreatedAt)
+	}
+
+	later := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	w = serve(server, http.MethodPost, "/createProduct", `{"name":"Chair","code":"CHR-1","createdAt":"`+later.Format(time.RFC3339)+`"}`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	var body WebError
+	decode(t, w, &body)
+	if body.Error != "createdAt must not be more than 5m0s in the future. Given: "+later.Format(time.RFC3339) {
+		t.Errorf("unexpected error: %q", body.Error)
+	}
+}
+
+func TestCreateAndUpdateValidateTheProduct(t *testing.T) {
+	tests := []struct{ body, message string }{
+		{`{"name":"   ","code":"DSK-1"}`, "name is required"},
+		{`{"name":"Desk","code":"   "}`, "code is required"},
+		{`{"name":"Desk","code":"` + strings.Repeat("A", 51) + `"}`, "code must be at most 50 characters. Given: 51 characters"},
+		{`{"name":"` + strings.Repeat("a", 51) + `","code":"DSK-1"}`, "name must be at most 50 characters. Given: 51 characters"},
+	}
+
+	for _, request := range []struct{ method, target string }{{http.MethodPost, "/createProduct"}, {http.MethodPut, "/updateProduct/1"}} {
+		for _, test := range tests {
+			server, _ := newTestServer(t, DefaultConfig(), storage.NewProduct("Desk", "DSK-1"))
+
+			w := serve(server, request.method, request.target, test.body)
+			expectStatus(t, w, http.StatusBadRequest)
+
+			var body WebError
+			decode(t, w, &body)
+			if body.Error != test.message {
+				t.Errorf("%s %s: expected %q, got %q", request.target, test.body, test.message, body.Error)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// viewCounter accumulates the views of products in memory, so reading a product doesn't write to
+// the database: the counts are added in a single statement every Config.ViewsFlushInterval.
+type viewCounter struct {
+	mu      sync.Mutex
+	pending map[int64]int64
+}
+
+// newViewCounter creates an empty viewCounter.
+func newViewCounter() *viewCounter {
+	return &viewCounter{pending: make(map[int64]int64)}
+}
+
+// record counts a view of the product.
+func (o *viewCounter) record(id int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.pending[id]++
+}
+
+// take returns the views counted so far and starts counting from zero again.
+func (o *viewCounter) take() map[int64]int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	views := o.pending
+	o.pending = make(map[int64]int64)
+	return views
+}
+
+// flushViews writes the views counted so far. Counting is best-effort: views that couldn't be
+// written are logged and dropped rather than retried, so a failing database can't make them pile up.
+func (o *Server) flushViews() {
+	views := o.views.take()
+	if len(views) == 0 {
+		return
+	}
+	if err := o.db.AddProductViews(context.Background(), views); err != nil {
+		slog.Error("product views couldn't be saved", "products", len(views), "error", err.Error())
+	}
+}
+
+// flushViewsEvery calls flushViews every interval until stop is closed.
+func (o *Server) flushViewsEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			o.flushViews()
+		case <-stop:
+			return
+		}
+	}
+}
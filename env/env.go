@@ -0,0 +1,74 @@
+// Package env provides helpers to read typed configuration values from environment variables.
+
+package env
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String returns the value of the environment variable key, or fallback when it is unset or empty.
+func String(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// Int returns the environment variable key parsed as an integer, or fallback when it is unset or empty.
+func Int(key string, fallback int) (int, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer. Given: %s", key, v)
+	}
+	return n, nil
+}
+
+// Bool returns the environment variable key parsed as a boolean, or fallback when it is unset or empty.
+func Bool(key string, fallback bool) (bool, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean. Given: %s", key, v)
+	}
+	return b, nil
+}
+
+// Duration returns the environment variable key parsed as a time.Duration, or fallback when it is unset or empty.
+func Duration(key string, fallback time.Duration) (time.Duration, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration such as 500ms or 10s. Given: %s", key, v)
+	}
+	return d, nil
+}
+
+// List returns the environment variable key split on commas, with blank items dropped, or fallback
+// when it is unset. Unlike the other helpers, an empty value gives an empty list, so defaults can be cleared.
+func List(key string, fallback []string) []string {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	items := make([]string, 0)
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
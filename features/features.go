@@ -0,0 +1,81 @@
+// Package features provides the flags turning optional behaviors of the server on and off without recompiling.
+
+package features
+
+import (
+	"apiGo/env"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Names of the features that can be toggled.
+const (
+	Admin       = "admin"       // The /admin endpoints, which still require ADMIN_API_KEY.
+	Compression = "compression" // Compressed responses, with the algorithms of COMPRESSION_ALGORITHMS.
+	LongPoll    = "longPoll"    // The /changes/longpoll endpoint.
+	Streaming   = "streaming"   // Listings written one product at a time instead of encoded as a whole first.
+)
+
+// known lists every feature, so typos in the configuration are caught instead of ignored.
+var known = []string{Admin, Compression, LongPoll, Streaming}
+
+// Flags tells which features are enabled. Features missing from it are disabled.
+type Flags map[string]bool
+
+// Defaults returns the flags used when nothing is overridden, with every feature enabled.
+func Defaults() Flags {
+	flags := make(Flags, len(known))
+	for _, name := range known {
+		flags[name] = true
+	}
+	return flags
+}
+
+// Enabled tells whether the feature name is enabled.
+func (o Flags) Enabled(name string) bool {
+	return o[name]
+}
+
+// Load returns the defaults, overridden first by the JSON object of booleans stored in the file
+// FEATURES_FILE names, if any, then by FEATURES, a comma-separated list of features to enable,
+// or to disable when prefixed with a dash, such as "-streaming,-longPoll".
+func Load() (Flags, error) {
+	flags := Defaults()
+
+	if path := env.String("FEATURES_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("FEATURES_FILE couldn't be read: %w", err)
+		}
+		var overrides map[string]bool
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return nil, fmt.Errorf("FEATURES_FILE must hold a JSON object of booleans: %w", err)
+		}
+		for name, enabled := range overrides {
+			if err := flags.set(name, enabled); err != nil {
+				return nil, fmt.Errorf("FEATURES_FILE: %w", err)
+			}
+		}
+	}
+
+	for _, item := range env.List("FEATURES", nil) {
+		name, disabled := strings.CutPrefix(item, "-")
+		if err := flags.set(name, !disabled); err != nil {
+			return nil, fmt.Errorf("FEATURES: %w", err)
+		}
+	}
+
+	return flags, nil
+}
+
+// set enables or disables the feature name, which must be known.
+func (o Flags) set(name string, enabled bool) error {
+	if !slices.Contains(known, name) {
+		return fmt.Errorf("unknown feature %s. Known: %s", name, strings.Join(known, ", "))
+	}
+	o[name] = enabled
+	return nil
+}
@@ -0,0 +1,55 @@
+package features
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDefaultsToEveryFeature(t *testing.T) {
+	t.Setenv("FEATURES", "")
+	t.Setenv("FEATURES_FILE", "")
+
+	flags, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range known {
+		if !flags.Enabled(name) {
+			t.Errorf("%s should be enabled by default", name)
+		}
+	}
+	if flags.Enabled("unknown") {
+		t.Error("unknown features must be disabled")
+	}
+}
+
+func TestLoadAppliesTheFileThenTheList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "features.json")
+	if err := os.WriteFile(path, []byte(`{"longPoll": false, "admin": false}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FEATURES_FILE", path)
+	t.Setenv("FEATURES", "-streaming, admin")
+
+	flags, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{Admin: true, Compression: true, LongPoll: false, Streaming: false}
+	for name, enabled := range expected {
+		if flags.Enabled(name) != enabled {
+			t.Errorf("%s: expected enabled=%t", name, enabled)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownFeatures(t *testing.T) {
+	t.Setenv("FEATURES_FILE", "")
+	t.Setenv("FEATURES", "-streming")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "unknown feature streming") {
+		t.Fatalf("expected the typo to be reported, got %v", err)
+	}
+}
@@ -2,35 +2,97 @@ package main
 
 import (
 	"apiGo/api"
+	"apiGo/env"
 	"apiGo/storage"
+	"apiGo/telemetry"
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 )
 
+// exitAddressInUse is the exit code used when the listen address is taken by another process.
+const exitAddressInUse = 3
+
 func main() {
+	// Export traces when an OTLP endpoint is configured.
+	shutdownTracing, err := telemetry.Setup(context.Background())
+	if err != nil {
+		slog.Error("tracing couldn't be set up", "error", err.Error())
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("pending spans couldn't be exported", "error", err.Error())
+		}
+	}()
+
 	// Initialize and start the database.
 	db, err := storage.NewPgStorage()
 	if err != nil {
-		slog.Error("db couldn't start")
+		slog.Error("db couldn't start", "error", err.Error())
 		os.Exit(1)
 	}
 
 	if err = db.Init(); err != nil {
-		slog.Error("db couldn't be initialized")
+		slog.Error("db couldn't be initialized", "error", err.Error())
+		os.Exit(1)
+	}
+
+	config, err := api.LoadConfig()
+	if err != nil {
+		slog.Error("invalid configuration", "error", err.Error())
+		os.Exit(1)
+	}
+
+	batchConfig, err := storage.LoadBatchConfig()
+	if err != nil {
+		slog.Error("invalid configuration", "error", err.Error())
+		os.Exit(1)
+	}
+
+	// Trace every storage call, optionally log it, and buffer product creations into multi-row inserts.
+	store := storage.Wrap(db, storage.TraceCalls)
+	logStorage, err := env.Bool("STORAGE_LOG_CALLS", false)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err.Error())
 		os.Exit(1)
 	}
+	if logStorage {
+		store = storage.Wrap(store, storage.LogCalls)
+	}
+	if batchConfig.Enabled {
+		store = storage.NewBatchStorage(store, batchConfig)
+	}
+
+	// Closed once the server has shut down, after flushing the products still queued, if any.
+	defer func() {
+		if err := store.Close(); err != nil {
+			slog.Error("storage couldn't be closed cleanly", "error", err.Error())
+		}
+	}()
 
 	// Create a new instance of the API server.
-	apiServer := api.NewApiServer(":8080", db)
+	listenAddr := env.String("LISTEN_ADDR", ":8080")
+	apiServer := api.NewApiServerWithConfig(listenAddr, store, config)
 
 	// Set up API endpoints and their handlers.
 	apiServer.HandleEndpoints()
 
 	// Start the API server.
-	fmt.Println("Server running in 8080...")
+	fmt.Printf("Server running in %s...\n", listenAddr)
 	if err := apiServer.Run(); err != nil {
-		slog.Error("server couldn't start")
+		if errors.Is(err, api.ErrAddressInUse) {
+			slog.Error("server couldn't start: the listen address is taken, stop the process using it or choose another address", "error", err.Error())
+			os.Exit(exitAddressInUse)
+		}
+		if errors.Is(err, api.ErrShutdownTimeout) {
+			// Returning rather than exiting still flushes queued products and pending spans.
+			slog.Error("server didn't stop cleanly", "error", err.Error())
+			return
+		}
+		slog.Error("server couldn't start", "error", err.Error())
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"apiGo/env"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrBatchClosed is returned when a product is queued after the BatchStorage was closed.
+var ErrBatchClosed = errors.New("batch writer is closed")
+
+// maxBatchSize keeps a multi-row insert below the Postgres limit of 65535 bind parameters.
+const maxBatchSize = 1000
+
+// BatchConfig configures the write batching of BatchStorage.
+type BatchConfig struct {
+	Enabled       bool          // Whether product creations are batched at all.
+	Size          int           // Number of queued products that triggers a flush.
+	FlushInterval time.Duration // Maximum time between flushes of a non-empty queue.
+	DrainTimeout  time.Duration // Maximum time to flush the queue when shutting down.
+}
+
+// LoadBatchConfig reads the batching configuration from the environment.
+func LoadBatchConfig() (BatchConfig, error) {
+	var (
+		config BatchConfig
+		err    error
+	)
+
+	if config.Enabled, err = env.Bool("BATCH_WRITES_ENABLED", false); err != nil {
+		return config, err
+	}
+	if config.Size, err = env.Int("BATCH_SIZE", 100); err != nil {
+		return config, err
+	}
+	if config.FlushInterval, err = env.Duration("BATCH_FLUSH_INTERVAL", 50*time.Millisecond); err != nil {
+		return config, err
+	}
+	if config.DrainTimeout, err = env.Duration("BATCH_DRAIN_TIMEOUT", 10*time.Second); err != nil {
+		return config, err
+	}
+
+	if config.Size < 1 || config.Size > maxBatchSize {
+		return config, fmt.Errorf("BATCH_SIZE must be between 1 and %d. Given: %d", maxBatchSize, config.Size)
+	}
+	if config.FlushInterval <= 0 {
+		return config, fmt.Errorf("BATCH_FLUSH_INTERVAL must be positive. Given: %s", config.FlushInterval)
+	}
+
+	return config, nil
+}
+
+// BatchInserter is implemented by storages able to insert several products with a single statement.
+type BatchInserter interface {
+	CreateProducts(context.Context, []*Product) ([]*Product, error)
+}
+
+// BatchableStorage is a Storage that also supports multi-row inserts.
+type BatchableStorage interface {
+	Storage
+	BatchInserter
+}
+
+// BatchStorage is a Storage decorator that buffers product creations and writes them
+// with a single multi-row insert, either when the buffer is full or when the flush interval elapses.
+type BatchStorage struct {
+	Storage
+	inserter BatchInserter
+	config   BatchConfig
+
+	mu     sync.RWMutex // Guards closed against concurrent sends on queue.
+	closed bool
+	queue  chan *batchItem
+	done   chan struct{}
+}
+
+// batchItem is a queued product and, for synchronous callers, the channel receiving the flush result.
+type batchItem struct {
+	product *Product
+	result  chan error
+	flushed func(*Product) // Called once an asynchronously queued product is written.
+}
+
+// NewBatchStorage creates a BatchStorage around s and starts its flushing goroutine.
+func NewBatchStorage(s BatchableStorage, config BatchConfig) *BatchStorage {
+	o := &BatchStorage{
+		Storage:  s,
+		inserter: s,
+		config:   config,
+		queue:    make(chan *batchItem, config.Size),
+		done:     make(chan struct{}),
+	}
+	go o.run()
+	return o
+}
+
+// CreateProduct queues the product and waits until the batch containing it has been flushed.
+// When ctx is done first, its error is returned but the product stays queued and is still written.
+func (o *BatchStorage) CreateProduct(ctx context.Context, p *Product) (*Product, error) {
+	item := &batchItem{product: p, result: make(chan error, 1)}
+	if err := o.enqueue(item); err != nil {
+		return nil, err
+	}
+
+	select {
+	case err := <-item.result:
+		if err != nil {
+			return nil, err
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return p, nil
+}
+
+// EnqueueProduct reserves the ID of the product and queues it without waiting for it to be written.
+// flushed, unless nil, is called with the product once it's written. Flush errors are only logged.
+func (o *BatchStorage) EnqueueProduct(ctx context.Context, p *Product, flushed func(*Product)) error {
+	id, err := o.Storage.ReserveProductId(ctx)
+	if err != nil {
+		return err
+	}
+	p.Id, p.idReserved = id, true
+
+	return o.enqueue(&batchItem{product: p, flushed: flushed})
+}
+
+// Drain stops accepting new products and waits until every queued product has been flushed,
+// or until ctx is done, in which case the flush keeps going in the background and ctx's error is returned.
+func (o *BatchStorage) Drain(ctx context.Context) error {
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.queue)
+	}
+	o.mu.Unlock()
+
+	select {
+	case <-o.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close drains the queue, waiting at most BatchConfig.DrainTimeout, then closes the decorated storage.
+func (o *BatchStorage) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), o.config.DrainTimeout)
+	defer cancel()
+
+	drainErr := o.Drain(ctx)
+	if drainErr != nil {
+		drainErr = fmt.Errorf("queued products couldn't be flushed: %w", drainErr)
+	}
+	return errors.Join(drainErr, o.Storage.Close())
+}
+
+// enqueue hands the item over to the flushing goroutine.
+func (o *BatchStorage) enqueue(item *batchItem) error {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.closed {
+		return ErrBatchClosed
+	}
+	o.queue <- item
+
+	return nil
+}
+
+// run collects queued items and flushes them by size or by time until the queue is closed.
+func (o *BatchStorage) run() {
+	defer close(o.done)
+
+	ticker := time.NewTicker(o.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*batchItem, 0, o.config.Size)
+	for {
+		select {
+		case item, ok := <-o.queue:
+			if !ok {
+				o.flush(batch)
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= o.config.Size {
+				o.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			o.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush writes the batch and reports the outcome to every waiting caller.
+//
+// A duplicate code fails the whole multi-row insert, so the batch is then written again one product
+// at a time, and only the products that conflict get ErrDuplicateCode.
+func (o *BatchStorage) flush(batch []*batchItem) {
+	if len(batch) == 0 {
+		return
+	}
+
+	products := make([]*Product, len(batch))
+	for i, item := range batch {
+		products[i] = item.product
+	}
+
+	// The batch mixes products of several requests, so it isn't bound to any of their contexts.
+	_, err := o.inserter.CreateProducts(context.Background(), products)
+	if errors.Is(err, ErrDuplicateCode) && len(batch) > 1 {
+		for _, item := range batch {
+			o.flush([]*batchItem{item})
+		}
+		return
+	}
+	if err != nil {
+		slog.Error("batched insert failed", "size", len(batch), "error", err.Error())
+	}
+
+	for _, item := range batch {
+		switch {
+		case item.result != nil:
+			item.result <- err
+		case err == nil && item.flushed != nil:
+			item.flushed(item.product)
+		}
+	}
+}
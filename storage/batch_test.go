@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchStorageReportsDuplicateCodesToTheConflictingProductOnly(t *testing.T) {
+	batch := NewBatchStorage(NewMemStorage(), BatchConfig{Enabled: true, Size: 3, FlushInterval: time.Hour, DrainTimeout: time.Second})
+	defer batch.Close()
+
+	codes := []string{"X1", "X2", "X1"}
+	errs := make([]error, len(codes))
+	var wg sync.WaitGroup
+	for i, code := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = batch.CreateProduct(context.Background(), NewProduct("Desk", code))
+		}()
+	}
+	wg.Wait()
+
+	if errs[1] != nil {
+		t.Fatalf("X2 has no conflict but failed: %v", errs[1])
+	}
+	duplicates := 0
+	for _, i := range []int{0, 2} {
+		switch {
+		case errors.Is(errs[i], ErrDuplicateCode):
+			duplicates++
+		case errs[i] != nil:
+			t.Fatalf("unexpected error for X1: %v", errs[i])
+		}
+	}
+	if duplicates != 1 {
+		t.Fatalf("exactly one X1 should be a duplicate, got %d", duplicates)
+	}
+}
+
+// recordingInserter is a MemStorage recording the size of every multi-row insert.
+type recordingInserter struct {
+	*MemStorage
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (o *recordingInserter) CreateProducts(ctx context.Context, products []*Product) ([]*Product, error) {
+	o.mu.Lock()
+	o.sizes = append(o.sizes, len(products))
+	o.mu.Unlock()
+	return o.MemStorage.CreateProducts(ctx, products)
+}
+
+func TestBatchStorageFlushesFullBatches(t *testing.T) {
+	inserter := &recordingInserter{MemStorage: NewMemStorage()}
+	batch := NewBatchStorage(inserter, BatchConfig{Enabled: true, Size: 3, FlushInterval: time.Hour, DrainTimeout: time.Second})
+	defer batch.Close()
+
+	var wg sync.WaitGroup
+	for _, code := range []string{"X1", "X2", "X3"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := batch.CreateProduct(context.Background(), NewProduct("Desk", code)); err != nil {
+				t.Errorf("%s couldn't be created: %v", code, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(inserter.sizes) != 1 || inserter.sizes[0] != 3 {
+		t.Fatalf("expected a single insert of 3 products, got %v", inserter.sizes)
+	}
+}
+
+func TestBatchStorageFlushesPartialBatchesAfterTheInterval(t *testing.T) {
+	inserter := &recordingInserter{MemStorage: NewMemStorage()}
+	batch := NewBatchStorage(inserter, BatchConfig{Enabled: true, Size: 10, FlushInterval: 20 * time.Millisecond, DrainTimeout: time.Second})
+	defer batch.Close()
+
+	start := time.Now()
+	if _, err := batch.CreateProduct(context.Background(), NewProduct("Desk", "X1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("the partial batch took %s to be written", elapsed)
+	}
+	if len(inserter.sizes) != 1 || inserter.sizes[0] != 1 {
+		t.Fatalf("expected a single insert of 1 product, got %v", inserter.sizes)
+	}
+}
+
+func TestBatchStorageDrainFlushesQueuedProducts(t *testing.T) {
+	db := NewMemStorage()
+	batch := NewBatchStorage(db, BatchConfig{Enabled: true, Size: 10, FlushInterval: time.Hour, DrainTimeout: time.Second})
+
+	flushed := make(chan *Product, 3)
+	for _, code := range []string{"X1", "X2", "X3"} {
+		if err := batch.EnqueueProduct(context.Background(), NewProduct("Desk", code), func(p *Product) { flushed <- p }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := batch.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(flushed) != 3 {
+		t.Errorf("expected the 3 queued products to be flushed, got %d", len(flushed))
+	}
+	if count, _ := db.CountProducts(context.Background(), ProductFilter{}); count != 3 {
+		t.Errorf("expected 3 products to be written, got %d", count)
+	}
+	if err := batch.EnqueueProduct(context.Background(), NewProduct("Desk", "X4"), nil); !errors.Is(err, ErrBatchClosed) {
+		t.Errorf("expected ErrBatchClosed once drained, got %v", err)
+	}
+}
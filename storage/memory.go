@@ -0,0 +1,554 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MemStorage is a Storage keeping the products in memory, for tests that shouldn't need a database.
+// It follows the default PgStorage schema: IDs are assigned from 1 upwards, values the columns would
+// reject return ErrInvalidData, codes already used by another product ErrDuplicateCode, and missing
+// products ErrNotFound. The optional schema rules of PgStorage, such as the quota, required or
+// generated codes and the byte length unit, aren't applied.
+type MemStorage struct {
+	mu       sync.Mutex
+	products map[int64]*Product
+	locks    map[int64]*ProductLock
+	claims   map[int64]string // Worker claiming each claimed product.
+	nextId   int64
+}
+
+// NewMemStorage creates an empty MemStorage.
+func NewMemStorage() *MemStorage {
+	return &MemStorage{
+		products: make(map[int64]*Product),
+		locks:    make(map[int64]*ProductLock),
+		claims:   make(map[int64]string),
+		nextId:   1,
+	}
+}
+
+// checkProduct rejects the values the product columns would reject.
+func checkProduct(p *Product) error {
+	if !ValidStatus(p.Status) {
+		return fmt.Errorf("%w: invalid status %q", ErrInvalidData, p.Status)
+	}
+	if utf8.RuneCountInString(p.Name) > 50 || utf8.RuneCountInString(p.Code) > 50 {
+		return fmt.Errorf("%w: value too long for type character varying(50)", ErrInvalidData)
+	}
+	return nil
+}
+
+// checkCode returns ErrDuplicateCode when a product other than id has the code. Empty codes mean no
+// code and are never taken. The caller holds mu.
+func (o *MemStorage) checkCode(id int64, code string) error {
+	if code == "" {
+		return nil
+	}
+	for _, p := range o.products {
+		if p.Code == code && p.Id != id {
+			return fmt.Errorf("%w: %s", ErrDuplicateCode, code)
+		}
+	}
+	return nil
+}
+
+// insert stores a copy of p under a new ID, which is set on p. The caller holds mu.
+func (o *MemStorage) insert(p *Product) {
+	p.UpdatedAt = p.CreatedAt
+	if !p.idReserved {
+		p.Id = o.nextId
+		o.nextId++
+	}
+	stored := *p
+	o.products[p.Id] = &stored
+}
+
+// sorted returns copies of the stored products matching keep, ordered by ID. The caller holds mu.
+func (o *MemStorage) sorted(keep func(*Product) bool) []*Product {
+	products := make([]*Product, 0)
+	for _, p := range o.products {
+		if keep(p) {
+			stored := *p
+			products = append(products, &stored)
+		}
+	}
+	slices.SortFunc(products, func(a, b *Product) int { return cmp.Compare(a.Id, b.Id) })
+	return products
+}
+
+// The Storage methods work on copies of the stored values, under mu, and follow the behavior of PgStorage.
+
+func (o *MemStorage) CreateProduct(_ context.Context, p *Product) (*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := checkProduct(p); err != nil {
+		return nil, err
+	}
+	if err := o.checkCode(0, p.Code); err != nil {
+		return nil, err
+	}
+	o.insert(p)
+	return p, nil
+}
+
+func (o *MemStorage) CreateProductIfCodeAbsent(_ context.Context, p *Product) (*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := checkProduct(p); err != nil {
+		return nil, err
+	}
+	for _, stored := range o.products {
+		if stored.Code == p.Code {
+			return nil, ErrCodeExists
+		}
+	}
+	o.insert(p)
+	return p, nil
+}
+
+// CreateProducts stores every product, or none when one of them is rejected, like a multi-row insert.
+func (o *MemStorage) CreateProducts(_ context.Context, products []*Product) ([]*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	codes := make(map[string]bool)
+	for i, p := range products {
+		if err := checkProduct(p); err != nil {
+			return nil, &RowError{Index: i, Code: p.Code, Err: err}
+		}
+		if err := o.checkCode(0, p.Code); err != nil {
+			return nil, &RowError{Index: i, Code: p.Code, Err: err}
+		}
+		if p.Code != "" && codes[p.Code] {
+			return nil, &RowError{Index: i, Code: p.Code, Err: fmt.Errorf("%w: %s", ErrDuplicateCode, p.Code)}
+		}
+		codes[p.Code] = true
+	}
+	for _, p := range products {
+		o.insert(p)
+	}
+	return products, nil
+}
+
+func (o *MemStorage) ReserveProductId(_ context.Context) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	id := o.nextId
+	o.nextId++
+	return id, nil
+}
+
+func (o *MemStorage) GetProducts(_ context.Context, filter ProductFilter, page Page) ([]*Product, int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	products := o.matching(filter)
+	field, descending := strings.CutPrefix(page.Sort, "-")
+	if page.Sort == "" && filter.OnlyDuplicates {
+		field = "code"
+	}
+	if compare, ok := productComparisons[field]; ok {
+		// Stable, so ties keep the ID order of matching.
+		slices.SortStableFunc(products, func(a, b *Product) int {
+			if descending {
+				return compare(b, a)
+			}
+			return compare(a, b)
+		})
+	}
+
+	total := int64(len(products))
+	products = products[min(page.Offset, len(products)):]
+	if page.Limit > 0 {
+		products = products[:min(page.Limit, len(products))]
+	}
+	return products, total, nil
+}
+
+// productComparisons compares products by each of the fields GetProducts can be sorted by.
+var productComparisons = map[string]func(a, b *Product) int{
+	"id":        func(a, b *Product) int { return cmp.Compare(a.Id, b.Id) },
+	"name":      func(a, b *Product) int { return strings.Compare(a.Name, b.Name) },
+	"code":      func(a, b *Product) int { return strings.Compare(a.Code, b.Code) },
+	"createdAt": func(a, b *Product) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"views":     func(a, b *Product) int { return cmp.Compare(a.Views, b.Views) },
+}
+
+func (o *MemStorage) SearchProducts(_ context.Context, query string, page Page) ([]*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	query = strings.ToLower(query)
+	products := o.sorted(func(p *Product) bool { return strings.Contains(strings.ToLower(p.Name), query) })
+	products = products[min(page.Offset, len(products)):]
+	if page.Limit > 0 {
+		products = products[:min(page.Limit, len(products))]
+	}
+	return products, nil
+}
+
+func (o *MemStorage) CountProducts(_ context.Context, filter ProductFilter) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return int64(len(o.matching(filter))), nil
+}
+
+// matching returns the products of the filter, sorted by ID. The caller holds mu.
+func (o *MemStorage) matching(filter ProductFilter) []*Product {
+	codes := make(map[string]int)
+	for _, p := range o.products {
+		codes[p.Code]++
+	}
+
+	return o.sorted(func(p *Product) bool {
+		return (filter.Status == "" || p.Status == filter.Status) &&
+			(!filter.OnlyDuplicates || codes[p.Code] > 1) &&
+			(filter.IdFrom <= 0 || p.Id >= filter.IdFrom) &&
+			(filter.IdTo <= 0 || p.Id <= filter.IdTo)
+	})
+}
+
+func (o *MemStorage) GetRandomProducts(_ context.Context, n int) ([]*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	products := o.sorted(func(*Product) bool { return true })
+	rand.Shuffle(len(products), func(i, j int) { products[i], products[j] = products[j], products[i] })
+	return products[:min(n, len(products))], nil
+}
+
+func (o *MemStorage) GetNameCollisions(_ context.Context) ([]*NameCollision, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	codes := make(map[string]map[string]bool)
+	for _, p := range o.products {
+		if codes[p.Name] == nil {
+			codes[p.Name] = make(map[string]bool)
+		}
+		codes[p.Name][p.Code] = true
+	}
+
+	products := o.sorted(func(p *Product) bool { return len(codes[p.Name]) > 1 })
+	slices.SortStableFunc(products, func(a, b *Product) int { return strings.Compare(a.Name, b.Name) })
+
+	collisions := make([]*NameCollision, 0)
+	for _, p := range products {
+		if len(collisions) == 0 || collisions[len(collisions)-1].Name != p.Name {
+			collisions = append(collisions, &NameCollision{Name: p.Name})
+		}
+		collision := collisions[len(collisions)-1]
+		collision.Products = append(collision.Products, p)
+	}
+	return collisions, nil
+}
+
+func (o *MemStorage) GetProductById(_ context.Context, id int64) (*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	p, ok := o.products[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	stored := *p
+	return &stored, nil
+}
+
+func (o *MemStorage) GetProductByCode(_ context.Context, code string) (*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	products := o.sorted(func(p *Product) bool { return p.Code == code })
+	if len(products) == 0 {
+		return nil, ErrNotFound
+	}
+	return products[0], nil
+}
+
+func (o *MemStorage) GetProductsByIds(_ context.Context, ids []int64) ([]*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.sorted(func(p *Product) bool { return slices.Contains(ids, p.Id) }), nil
+}
+
+func (o *MemStorage) UpdateProduct(_ context.Context, p *Product) (*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	stored, ok := o.products[p.Id]
+	if !p.UpdatedAt.IsZero() && (!ok || !stored.UpdatedAt.Equal(p.UpdatedAt)) {
+		return nil, ErrModified
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated := *stored
+	updated.Name, updated.Code, updated.UpdatedAt = p.Name, p.Code, time.Now().UTC()
+	if p.Status != "" {
+		updated.Status = p.Status
+	}
+	if err := checkProduct(&updated); err != nil {
+		return nil, err
+	}
+	if err := o.checkCode(p.Id, updated.Code); err != nil {
+		return nil, err
+	}
+
+	*stored = updated
+	return &updated, nil
+}
+
+func (o *MemStorage) PatchProduct(_ context.Context, id int64, patch ProductPatch) (*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	stored, ok := o.products[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated := *stored
+	if patch.Name != nil {
+		updated.Name = *patch.Name
+	}
+	if patch.Code != nil {
+		updated.Code = *patch.Code
+	}
+	if patch.Status != nil {
+		updated.Status = *patch.Status
+	}
+	updated.UpdatedAt = time.Now().UTC()
+	if err := checkProduct(&updated); err != nil {
+		return nil, err
+	}
+	if err := o.checkCode(id, updated.Code); err != nil {
+		return nil, err
+	}
+
+	*stored = updated
+	return &updated, nil
+}
+
+func (o *MemStorage) ProductExists(_ context.Context, id int64) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, ok := o.products[id]
+	return ok, nil
+}
+
+func (o *MemStorage) SetProductStatus(_ context.Context, id int64, status string) (*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	stored, ok := o.products[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if stored.Status != status && !slices.Contains(statusTransitions[stored.Status], status) {
+		return nil, ErrInvalidTransition
+	}
+
+	if stored.Status != status {
+		stored.Status = status
+		stored.UpdatedAt = time.Now().UTC()
+	}
+	p := *stored
+	return &p, nil
+}
+
+func (o *MemStorage) LockProduct(_ context.Context, id int64, holder string, ttl time.Duration) (*ProductLock, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.products[id]; !ok {
+		return nil, ErrNotFound
+	}
+	now := time.Now().UTC()
+	if current, ok := o.locks[id]; ok && current.Holder != holder && current.ExpiresAt.After(now) {
+		return nil, ErrProductLocked
+	}
+
+	lock := &ProductLock{ProductId: id, Holder: holder, ExpiresAt: now.Add(ttl)}
+	o.locks[id] = lock
+	stored := *lock
+	return &stored, nil
+}
+
+func (o *MemStorage) GetProductLock(_ context.Context, id int64) (*ProductLock, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	lock, ok := o.locks[id]
+	if !ok || !lock.ExpiresAt.After(time.Now().UTC()) {
+		return nil, nil
+	}
+	stored := *lock
+	return &stored, nil
+}
+
+func (o *MemStorage) NormalizeCodes(_ context.Context) (*CodeNormalization, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	result := &CodeNormalization{Collisions: make([]*Product, 0), Normalized: make([]*Product, 0)}
+	products := o.sorted(func(*Product) bool { return true })
+
+	// Codes already in their normal form are kept first, as in PgStorage.
+	taken := make(map[string]bool)
+	for _, p := range products {
+		if normalizeCode(p.Code) == p.Code {
+			taken[p.Code] = true
+		}
+	}
+
+	for _, p := range products {
+		code := normalizeCode(p.Code)
+		if code == p.Code {
+			continue
+		}
+		if code != "" && taken[code] {
+			result.Collisions = append(result.Collisions, p)
+			continue
+		}
+
+		o.products[p.Id].Code = code
+		o.products[p.Id].UpdatedAt = time.Now().UTC()
+		taken[code] = true
+		result.Changed++
+		normalized := *o.products[p.Id]
+		result.Normalized = append(result.Normalized, &normalized)
+	}
+	return result, nil
+}
+
+func (o *MemStorage) ReplaceSubstring(_ context.Context, field, from, to string) ([]*Product, error) {
+	if !slices.Contains(ReplaceableFields, field) {
+		return nil, fmt.Errorf("field %q can't be replaced", field)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// Every product is checked before any is changed, like the single update statement of PgStorage.
+	replaced := make(map[int64]Product)
+	for id, p := range o.products {
+		updated := *p
+		value := &updated.Name
+		if field == "code" {
+			value = &updated.Code
+		}
+		if from == "" || !strings.Contains(*value, from) {
+			continue
+		}
+
+		*value = strings.ReplaceAll(*value, from, to)
+		updated.UpdatedAt = time.Now().UTC()
+		if err := checkProduct(&updated); err != nil {
+			return nil, err
+		}
+		replaced[id] = updated
+	}
+
+	// Replaced codes must stay unique, like the unique index requires.
+	codes := make(map[string]bool)
+	for id, p := range o.products {
+		code := p.Code
+		if updated, ok := replaced[id]; ok {
+			code = updated.Code
+		}
+		if code != "" && codes[code] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateCode, code)
+		}
+		codes[code] = true
+	}
+
+	products := make([]*Product, 0, len(replaced))
+	for id, p := range replaced {
+		*o.products[id] = p
+		products = append(products, &p)
+	}
+	return products, nil
+}
+
+func (o *MemStorage) DeleteProduct(_ context.Context, id int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.products[id]; !ok {
+		return ErrNotFound
+	}
+	delete(o.products, id)
+	delete(o.locks, id)
+	delete(o.claims, id)
+	return nil
+}
+
+func (o *MemStorage) MergeProducts(_ context.Context, keepId, mergeId int64) (*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	kept, ok := o.products[keepId]
+	merged, found := o.products[mergeId]
+	if !ok || !found {
+		return nil, ErrNotFound
+	}
+	kept.Views += merged.Views
+	kept.UpdatedAt = time.Now().UTC()
+	delete(o.products, mergeId)
+	delete(o.locks, mergeId)
+	delete(o.claims, mergeId)
+
+	stored := *kept
+	return &stored, nil
+}
+
+func (o *MemStorage) AddProductViews(_ context.Context, views map[int64]int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for id, count := range views {
+		if p, ok := o.products[id]; ok {
+			p.Views += count
+		}
+	}
+	return nil
+}
+
+func (o *MemStorage) ClaimProducts(_ context.Context, worker string, n int) ([]*Product, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	products := o.sorted(func(p *Product) bool {
+		_, claimed := o.claims[p.Id]
+		return !claimed
+	})
+	products = products[:max(0, min(n, len(products)))]
+	for _, p := range products {
+		o.claims[p.Id] = worker
+	}
+	return products, nil
+}
+
+// Ping always succeeds, there is nothing to reach.
+func (o *MemStorage) Ping(context.Context) error {
+	return nil
+}
+
+// Close does nothing, the products stay available.
+func (o *MemStorage) Close() error {
+	return nil
+}
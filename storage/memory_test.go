@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestConditionalUpdateFailsOnConcurrentChange(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemStorage()
+	read, err := db.CreateProduct(ctx, NewProduct("Desk", "DSK-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another writer updates the product after it was read.
+	if _, err := db.UpdateProduct(ctx, &Product{Id: read.Id, Name: "Table", Code: "DSK-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.UpdateProduct(ctx, &Product{Id: read.Id, Name: "Chair", Code: "DSK-1", UpdatedAt: read.UpdatedAt})
+	if !errors.Is(err, ErrModified) {
+		t.Fatalf("expected ErrModified, got %v", err)
+	}
+
+	stored, err := db.GetProductById(ctx, read.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Table" {
+		t.Errorf("the conditional update shouldn't have been applied, got %q", stored.Name)
+	}
+}
+
+func TestConcurrentClaimersNeverGetTheSameProduct(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemStorage()
+	for i := range 100 {
+		if _, err := db.CreateProduct(ctx, NewProduct("Desk", fmt.Sprintf("DSK-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	claimedBy := make(map[int64]string)
+	var wg sync.WaitGroup
+	for w := range 8 {
+		worker := fmt.Sprintf("worker-%d", w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				products, err := db.ClaimProducts(ctx, worker, 3)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(products) == 0 {
+					return
+				}
+
+				mu.Lock()
+				for _, p := range products {
+					if previous, found := claimedBy[p.Id]; found {
+						t.Errorf("product %d claimed by %s and %s", p.Id, previous, worker)
+					}
+					claimedBy[p.Id] = worker
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimedBy) != 100 {
+		t.Errorf("expected every product to be claimed once, got %d", len(claimedBy))
+	}
+}
+
+func TestOnlyDuplicatesListsProductsSharingTheirCode(t *testing.T) {
+	db := NewMemStorage()
+	// Inserted directly, as the default schema rejects duplicate codes.
+	for _, p := range []*Product{NewProduct("Desk", "DSK-1"), NewProduct("Chair", "CHR-1"), NewProduct("Desk", "DSK-1"), NewProduct("Lamp", "LMP-1")} {
+		db.insert(p)
+	}
+
+	products, total, err := db.GetProducts(context.Background(), ProductFilter{OnlyDuplicates: true}, Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(products) != 2 {
+		t.Fatalf("expected the 2 products sharing DSK-1, got %d: %+v", total, products)
+	}
+	for i, id := range []int64{1, 3} {
+		if products[i].Id != id || products[i].Code != "DSK-1" {
+			t.Errorf("expected product %d with DSK-1 at %d, got %+v", id, i, products[i])
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// StorageMiddleware decorates a Storage with a cross-cutting concern, such as logging or caching.
+type StorageMiddleware func(next Storage) Storage
+
+// Wrap decorates s with the middlewares. The first one is the outermost, so it sees every call first.
+func Wrap(s Storage, middlewares ...StorageMiddleware) Storage {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		s = middlewares[i](s)
+	}
+	return s
+}
+
+// LogCalls is a StorageMiddleware logging every storage call with its duration and error, if any.
+func LogCalls(next Storage) Storage {
+	return &loggingStorage{next: next}
+}
+
+// loggingStorage is the Storage returned by LogCalls.
+type loggingStorage struct {
+	next Storage
+}
+
+// log records a call started at start.
+func (o *loggingStorage) log(method string, start time.Time, err error) {
+	if err != nil {
+		slog.Info("storage call", "method", method, "duration", time.Since(start), "error", err.Error())
+		return
+	}
+	slog.Info("storage call", "method", method, "duration", time.Since(start))
+}
+
+// The Storage methods forward the call to next and log it.
+
+func (o *loggingStorage) CreateProduct(ctx context.Context, p *Product) (*Product, error) {
+	start := time.Now()
+	result, err := o.next.CreateProduct(ctx, p)
+	o.log("CreateProduct", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) CreateProductIfCodeAbsent(ctx context.Context, p *Product) (*Product, error) {
+	start := time.Now()
+	result, err := o.next.CreateProductIfCodeAbsent(ctx, p)
+	o.log("CreateProductIfCodeAbsent", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) CreateProducts(ctx context.Context, products []*Product) ([]*Product, error) {
+	start := time.Now()
+	result, err := o.next.CreateProducts(ctx, products)
+	o.log("CreateProducts", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) ReserveProductId(ctx context.Context) (int64, error) {
+	start := time.Now()
+	result, err := o.next.ReserveProductId(ctx)
+	o.log("ReserveProductId", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) GetProducts(ctx context.Context, filter ProductFilter, page Page) ([]*Product, int64, error) {
+	start := time.Now()
+	products, total, err := o.next.GetProducts(ctx, filter, page)
+	o.log("GetProducts", start, err)
+	return products, total, err
+}
+
+func (o *loggingStorage) SearchProducts(ctx context.Context, query string, page Page) ([]*Product, error) {
+	start := time.Now()
+	result, err := o.next.SearchProducts(ctx, query, page)
+	o.log("SearchProducts", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) CountProducts(ctx context.Context, filter ProductFilter) (int64, error) {
+	start := time.Now()
+	result, err := o.next.CountProducts(ctx, filter)
+	o.log("CountProducts", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) GetRandomProducts(ctx context.Context, n int) ([]*Product, error) {
+	start := time.Now()
+	result, err := o.next.GetRandomProducts(ctx, n)
+	o.log("GetRandomProducts", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) GetNameCollisions(ctx context.Context) ([]*NameCollision, error) {
+	start := time.Now()
+	result, err := o.next.GetNameCollisions(ctx)
+	o.log("GetNameCollisions", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) GetProductById(ctx context.Context, id int64) (*Product, error) {
+	start := time.Now()
+	result, err := o.next.GetProductById(ctx, id)
+	o.log("GetProductById", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) GetProductByCode(ctx context.Context, code string) (*Product, error) {
+	start := time.Now()
+	result, err := o.next.GetProductByCode(ctx, code)
+	o.log("GetProductByCode", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) GetProductsByIds(ctx context.Context, ids []int64) ([]*Product, error) {
+	start := time.Now()
+	result, err := o.next.GetProductsByIds(ctx, ids)
+	o.log("GetProductsByIds", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) UpdateProduct(ctx context.Context, p *Product) (*Product, error) {
+	start := time.Now()
+	result, err := o.next.UpdateProduct(ctx, p)
+	o.log("UpdateProduct", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) PatchProduct(ctx context.Context, id int64, patch ProductPatch) (*Product, error) {
+	start := time.Now()
+	result, err := o.next.PatchProduct(ctx, id, patch)
+	o.log("PatchProduct", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) ProductExists(ctx context.Context, id int64) (bool, error) {
+	start := time.Now()
+	result, err := o.next.ProductExists(ctx, id)
+	o.log("ProductExists", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) SetProductStatus(ctx context.Context, id int64, status string) (*Product, error) {
+	start := time.Now()
+	result, err := o.next.SetProductStatus(ctx, id, status)
+	o.log("SetProductStatus", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) LockProduct(ctx context.Context, id int64, holder string, ttl time.Duration) (*ProductLock, error) {
+	start := time.Now()
+	result, err := o.next.LockProduct(ctx, id, holder, ttl)
+	o.log("LockProduct", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) GetProductLock(ctx context.Context, id int64) (*ProductLock, error) {
+	start := time.Now()
+	result, err := o.next.GetProductLock(ctx, id)
+	o.log("GetProductLock", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) NormalizeCodes(ctx context.Context) (*CodeNormalization, error) {
+	start := time.Now()
+	result, err := o.next.NormalizeCodes(ctx)
+	o.log("NormalizeCodes", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) ReplaceSubstring(ctx context.Context, field, from, to string) ([]*Product, error) {
+	start := time.Now()
+	result, err := o.next.ReplaceSubstring(ctx, field, from, to)
+	o.log("ReplaceSubstring", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) DeleteProduct(ctx context.Context, id int64) error {
+	start := time.Now()
+	err := o.next.DeleteProduct(ctx, id)
+	o.log("DeleteProduct", start, err)
+	return err
+}
+
+func (o *loggingStorage) MergeProducts(ctx context.Context, keepId, mergeId int64) (*Product, error) {
+	start := time.Now()
+	result, err := o.next.MergeProducts(ctx, keepId, mergeId)
+	o.log("MergeProducts", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) AddProductViews(ctx context.Context, views map[int64]int64) error {
+	start := time.Now()
+	err := o.next.AddProductViews(ctx, views)
+	o.log("AddProductViews", start, err)
+	return err
+}
+
+func (o *loggingStorage) ClaimProducts(ctx context.Context, worker string, n int) ([]*Product, error) {
+	start := time.Now()
+	result, err := o.next.ClaimProducts(ctx, worker, n)
+	o.log("ClaimProducts", start, err)
+	return result, err
+}
+
+func (o *loggingStorage) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := o.next.Ping(ctx)
+	o.log("Ping", start, err)
+	return err
+}
+
+func (o *loggingStorage) Close() error {
+	start := time.Now()
+	err := o.next.Close()
+	o.log("Close", start, err)
+	return err
+}
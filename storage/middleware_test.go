@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+// callArgs builds arguments for a Storage method of type method, a product where one is expected.
+func callArgs(method reflect.Type) []reflect.Value {
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	args := make([]reflect.Value, method.NumIn())
+	for i := range args {
+		switch in := method.In(i); {
+		case in == contextType:
+			args[i] = reflect.ValueOf(context.Background())
+		case in == reflect.TypeOf(&Product{}):
+			args[i] = reflect.ValueOf(NewProduct("Desk", "DSK-1"))
+		default:
+			args[i] = reflect.Zero(in)
+		}
+	}
+	return args
+}
+
+func TestLogCallsLogsEveryMethod(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	store := reflect.ValueOf(Wrap(NewMemStorage(), LogCalls))
+	methods := reflect.TypeOf((*Storage)(nil)).Elem()
+	for i := range methods.NumMethod() {
+		method := store.MethodByName(methods.Method(i).Name)
+		method.Call(callArgs(method.Type()))
+	}
+
+	logged := make(map[string]int)
+	decoder := json.NewDecoder(&logs)
+	for decoder.More() {
+		var record struct {
+			Msg    string `json:"msg"`
+			Method string `json:"method"`
+		}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Msg == "storage call" {
+			logged[record.Method]++
+		}
+	}
+	for i := range methods.NumMethod() {
+		if name := methods.Method(i).Name; logged[name] != 1 {
+			t.Errorf("%s was logged %d times", name, logged[name])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"apiGo/env"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PoolConfig configures the connection pool of a PgStorage.
+type PoolConfig struct {
+	MaxOpenConns    int           // Connections open at once, in use or idle. Zero doesn't limit.
+	MaxIdleConns    int           // Idle connections kept for reuse.
+	ConnMaxLifetime time.Duration // Age after which a connection is closed instead of reused. Zero keeps them forever.
+}
+
+// LoadPoolConfig reads the connection pool configuration from the environment.
+func LoadPoolConfig() (PoolConfig, error) {
+	var (
+		config PoolConfig
+		err    error
+	)
+
+	if config.MaxOpenConns, err = env.Int("DB_MAX_OPEN_CONNS", 25); err != nil {
+		return config, err
+	}
+	if config.MaxIdleConns, err = env.Int("DB_MAX_IDLE_CONNS", 5); err != nil {
+		return config, err
+	}
+	if config.ConnMaxLifetime, err = env.Duration("DB_CONN_MAX_LIFETIME", 5*time.Minute); err != nil {
+		return config, err
+	}
+
+	if config.MaxOpenConns < 0 || config.MaxIdleConns < 0 || config.ConnMaxLifetime < 0 {
+		return config, fmt.Errorf("DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and DB_CONN_MAX_LIFETIME must not be negative")
+	}
+	if config.MaxOpenConns > 0 && config.MaxIdleConns > config.MaxOpenConns {
+		return config, fmt.Errorf("DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS. Given: %d", config.MaxIdleConns)
+	}
+
+	return config, nil
+}
+
+// Apply sets the configuration on the pool.
+func (o PoolConfig) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(o.MaxOpenConns)
+	db.SetMaxIdleConns(o.MaxIdleConns)
+	db.SetConnMaxLifetime(o.ConnMaxLifetime)
+}
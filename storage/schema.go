@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// column is a column expected by the current schema, with the definition used to add it.
+type column struct {
+	name       string // Lowercase, as reported by information_schema.
+	dataType   string // As reported by the data_type of information_schema.columns.
+	definition string
+}
+
+// productColumnsSchema are the columns of the product table, in the order they were introduced.
+var productColumnsSchema = []column{
+	{"id", "integer", "id serial primary key"},
+	{"name", "character varying", "name varchar(50)"},
+	{"code", "character varying", "code varchar(50)"},
+	{"createdat", "timestamp without time zone", "createdAt timestamp"},
+	{"status", "character varying", "status varchar(10) not null default 'active' check (status in ('active', 'inactive', 'draft'))"},
+	{"view_count", "bigint", "view_count bigint not null default 0"},
+	{"claimedby", "character varying", "claimedBy varchar(100)"},
+	{"claimedat", "timestamp without time zone", "claimedAt timestamp"},
+	{"updatedat", "timestamp without time zone", "updatedAt timestamp"},
+}
+
+// ensureColumns adds the expected columns missing from a table created by an older version of the
+// schema, logging each repair. Columns that exist are left untouched, but one whose type drifted makes
+// it return ErrSchemaMismatch, so the server fails at startup rather than on the queries reading it.
+func (o *PgStorage) ensureColumns(table string, columns []column) error {
+	rows, err := o.db.Query("select column_name, data_type from information_schema.columns where table_schema = current_schema() and table_name = $1", table)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]string)
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		existing[name] = dataType
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range columns {
+		if dataType, found := existing[c.name]; found {
+			if dataType != c.dataType {
+				return fmt.Errorf("%w: column %s.%s is %s, %s is expected. Migrate or drop the column", ErrSchemaMismatch, table, c.name, dataType, c.dataType)
+			}
+			continue
+		}
+		if _, err := o.db.Exec("alter table " + table + " add column if not exists " + c.definition); err != nil {
+			return err
+		}
+		slog.Info("repaired schema", "table", table, "column", c.name)
+	}
+
+	return nil
+}
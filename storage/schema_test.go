@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// existingColumns are the information_schema rows of a product table holding the first n columns.
+func existingColumns(n int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"column_name", "data_type"})
+	for _, c := range productColumnsSchema[:n] {
+		rows.AddRow(c.name, c.dataType)
+	}
+	return rows
+}
+
+func TestEnsureColumnsAddsTheMissingColumns(t *testing.T) {
+	db, mock := newMockStorage(t)
+	// The table as the first version of the schema created it.
+	mock.ExpectQuery("from information_schema.columns").WithArgs("product").WillReturnRows(existingColumns(4))
+	for _, c := range productColumnsSchema[4:] {
+		mock.ExpectExec(regexp.QuoteMeta("alter table product add column if not exists " + c.definition)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	if err := db.ensureColumns("product", productColumnsSchema); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnsureColumnsLeavesACurrentTableAlone(t *testing.T) {
+	db, mock := newMockStorage(t)
+	mock.ExpectQuery("from information_schema.columns").WithArgs("product").WillReturnRows(existingColumns(len(productColumnsSchema)))
+
+	if err := db.ensureColumns("product", productColumnsSchema); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitFailsOnAColumnOfAnotherType(t *testing.T) {
+	db, mock := newMockStorage(t)
+	mock.ExpectExec("create table if not exists product").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("from information_schema.columns").WithArgs("product").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "data_type"}).AddRow("id", "integer").AddRow("name", "integer"))
+
+	err := db.Init()
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected ErrSchemaMismatch, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "column product.name is integer, character varying is expected") {
+		t.Errorf("expected the error to describe the column, got %q", err)
+	}
+}
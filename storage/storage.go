@@ -1,11 +1,18 @@
 package storage
 
 import (
+	"apiGo/env"
+	"cmp"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"log/slog"
 	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -15,47 +22,432 @@ type Product struct {
 	Name      string    `json:"name"`
 	Code      string    `json:"code"`
 	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"` // Last modification, the creation time until the first one.
+	Status    string    `json:"status"`
+	Views     int64     `json:"views"` // Times the product was read on its own, updated asynchronously.
+
+	idReserved bool // Whether Id was drawn with ReserveProductId, so inserts keep it.
+}
+
+// Lifecycle statuses of a product.
+const (
+	StatusActive   = "active"
+	StatusInactive = "inactive"
+	StatusDraft    = "draft"
+)
+
+// statusTransitions lists the statuses each status can move to through SetProductStatus.
+var statusTransitions = map[string][]string{
+	StatusDraft:    {StatusActive},
+	StatusActive:   {StatusInactive},
+	StatusInactive: {StatusActive},
+}
+
+// ValidStatus reports whether status is one of the product lifecycle statuses.
+func ValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// ErrInvalidTransition is returned when a product can't move from its current status to the requested one.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// Units of the name and code length limit, selected with FIELD_LENGTH_UNIT.
+const (
+	LengthUnitRunes = "runes" // Characters, as counted by varchar.
+	LengthUnitBytes = "bytes" // Bytes of the UTF-8 encoding, enforced with an octet_length check.
+)
+
+// LoadLengthUnit reads the unit of the name and code length limit from the environment.
+func LoadLengthUnit() (string, error) {
+	unit := env.String("FIELD_LENGTH_UNIT", LengthUnitRunes)
+	if unit != LengthUnitRunes && unit != LengthUnitBytes {
+		return "", fmt.Errorf("FIELD_LENGTH_UNIT must be %s or %s. Given: %s", LengthUnitRunes, LengthUnitBytes, unit)
+	}
+	return unit, nil
 }
 
+// productColumns are the columns scanned by scanProduct, in order. Rows saved before updatedAt
+// existed count as never modified.
+const productColumns = "id, name, code, createdAt, coalesce(updatedAt, createdAt), status, view_count"
+
+// ProductFilter narrows down the products returned by GetProducts. Zero values don't filter.
+type ProductFilter struct {
+	Status         string // Only products with this status.
+	OnlyDuplicates bool   // Only products whose code is shared with another product, ordered by code.
+	IdFrom         int64  // Only products with this ID or a greater one. Zero doesn't bound.
+	IdTo           int64  // Only products with this ID or a lower one. Zero doesn't bound.
+}
+
+// Page selects a window of the products returned by GetProducts.
+type Page struct {
+	Limit  int    // Maximum number of products. Zero doesn't limit.
+	Offset int    // Number of products skipped.
+	Sort   string // A field of sortColumns, prefixed with a dash for descending order. Empty sorts by ID.
+}
+
+// sortColumns maps the fields GetProducts can be sorted by, through Page.Sort, to their column. Only
+// these columns can reach the order by clause, so the sort parameter can't inject SQL.
+var sortColumns = map[string]string{
+	"id":        "id",
+	"name":      "name",
+	"code":      "code",
+	"createdAt": "createdAt",
+	"views":     "view_count",
+}
+
+// ValidSort reports whether sort is one of the orders of GetProducts: empty, for the default, or a field
+// of sortColumns, prefixed with a dash for descending order.
+func ValidSort(sort string) bool {
+	if sort == "" {
+		return true
+	}
+	field, _ := strings.CutPrefix(sort, "-")
+	_, ok := sortColumns[field]
+	return ok
+}
+
+// ErrCodeExists is returned by a conditional creation when a product with the same code exists.
+var ErrCodeExists = errors.New("a product with this code already exists")
+
+// ErrNotInitialized is returned when the schema doesn't exist because Init was never run.
+var ErrNotInitialized = errors.New("storage is not initialized")
+
+// ErrSchemaMismatch is returned by Init when an existing column has another type than the schema expects.
+var ErrSchemaMismatch = errors.New("the database schema doesn't match the expected one")
+
+// ErrDuplicateCode is returned when a write would give a product the code of another product, which
+// CODE_UNIQUE forbids.
+var ErrDuplicateCode = errors.New("another product already has this code")
+
+// ErrQuotaExceeded is returned when creating products would go beyond the configured quota.
+var ErrQuotaExceeded = errors.New("the product quota has been reached")
+
+// ErrInvalidData is returned when the database rejects a value, such as one too long for its column.
+var ErrInvalidData = errors.New("invalid product data")
+
+// ErrNotFound is returned when the product to read or modify doesn't exist.
+var ErrNotFound = errors.New("product not found")
+
+// ErrModified is returned when a conditional update finds the product modified since it was read.
+var ErrModified = errors.New("product was modified since it was read")
+
+// ErrProductLocked is returned when a product is locked by another holder.
+var ErrProductLocked = errors.New("product is locked by another holder")
+
+// RowError is returned by CreateProducts when one of the products is rejected, naming it so callers
+// can report it against their input.
+type RowError struct {
+	Index int    // Position of the product in the slice given to CreateProducts.
+	Code  string // Code of the product.
+	Err   error  // Why the product was rejected, such as ErrDuplicateCode.
+}
+
+// Error describes the rejected product and the reason.
+func (o *RowError) Error() string {
+	return fmt.Sprintf("product %d with code %q: %v", o.Index, o.Code, o.Err)
+}
+
+// Unwrap returns the reason, so errors.Is still matches it.
+func (o *RowError) Unwrap() error {
+	return o.Err
+}
+
+// ProductLock represents a short-lived claim of a product by an editor.
+type ProductLock struct {
+	ProductId int64     `json:"productId"`
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// NameCollision is a group of products sharing the same name with different codes.
+type NameCollision struct {
+	Name     string     `json:"name"`
+	Products []*Product `json:"products"`
+}
+
+// CodeNormalization reports the outcome of NormalizeCodes.
+type CodeNormalization struct {
+	Changed    int64      `json:"changed"`
+	Collisions []*Product `json:"collisions"` // Products left untouched because their normalized code is taken.
+	Normalized []*Product `json:"-"`          // Products whose code changed, as stored.
+}
+
+// ProductPatch holds the fields PatchProduct modifies. Nil fields are left unchanged.
+type ProductPatch struct {
+	Name   *string
+	Code   *string
+	Status *string
+}
+
+// Empty reports whether the patch modifies no field.
+func (o ProductPatch) Empty() bool {
+	return o.Name == nil && o.Code == nil && o.Status == nil
+}
+
+// ReplaceableFields are the product columns ReplaceSubstring accepts.
+var ReplaceableFields = []string{"name", "code"}
+
 // NewProduct creates a new Product instance with the provided name and code.
 func NewProduct(name, code string) *Product {
+	now := time.Now().UTC()
 	return &Product{
 		Id:        rand.Int64(),
 		Name:      name,
 		Code:      code,
-		CreatedAt: time.Now().UTC(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Status:    StatusActive,
 	}
 }
 
 // Storage is an interface for interacting with product data.
 type Storage interface {
-	CreateProduct(*Product) (*Product, error)
-	GetProducts() ([]*Product, error)
-	GetProductById(int64) (*Product, error)
-	UpdateProduct(*Product) (*Product, error)
+	CreateProduct(context.Context, *Product) (*Product, error)
+	CreateProductIfCodeAbsent(context.Context, *Product) (*Product, error)
+	CreateProducts(context.Context, []*Product) ([]*Product, error)
+	ReserveProductId(context.Context) (int64, error)
+	GetProducts(context.Context, ProductFilter, Page) ([]*Product, int64, error)
+	CountProducts(context.Context, ProductFilter) (int64, error)
+	SearchProducts(ctx context.Context, query string, page Page) ([]*Product, error)
+	GetRandomProducts(ctx context.Context, n int) ([]*Product, error)
+	GetNameCollisions(context.Context) ([]*NameCollision, error)
+	GetProductById(context.Context, int64) (*Product, error)
+	GetProductByCode(ctx context.Context, code string) (*Product, error)
+	GetProductsByIds(context.Context, []int64) ([]*Product, error)
+	UpdateProduct(context.Context, *Product) (*Product, error)
+	PatchProduct(ctx context.Context, id int64, patch ProductPatch) (*Product, error)
+	ProductExists(context.Context, int64) (bool, error)
+	SetProductStatus(ctx context.Context, id int64, status string) (*Product, error)
+	LockProduct(ctx context.Context, id int64, holder string, ttl time.Duration) (*ProductLock, error)
+	GetProductLock(context.Context, int64) (*ProductLock, error)
+	NormalizeCodes(context.Context) (*CodeNormalization, error)
+	ReplaceSubstring(ctx context.Context, field, from, to string) ([]*Product, error)
+	DeleteProduct(context.Context, int64) error
+	MergeProducts(ctx context.Context, keepId, mergeId int64) (*Product, error)
+	AddProductViews(ctx context.Context, views map[int64]int64) error
+	ClaimProducts(ctx context.Context, worker string, n int) ([]*Product, error)
+	Ping(context.Context) error
+	Close() error
 }
 
+// Postgres error codes of transactions aborted by a conflict, which are safe to run again.
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// pgUndefinedTable is the Postgres error code of a query on a table that doesn't exist.
+const pgUndefinedTable = "42P01"
+
+// Postgres error codes of values rejected by the schema.
+const (
+	pgStringTooLong   = "22001"
+	pgCheckViolation  = "23514"
+	pgUniqueViolation = "23505"
+)
+
+// retryDelay is the base wait before running a conflicting statement again. A random jitter of
+// the same magnitude is added so that the transactions involved don't collide again.
+const retryDelay = 10 * time.Millisecond
+
 // PgStorage represents PostgreSQL storage implementation.
 type PgStorage struct {
-	db *sql.DB
+	db                  *sql.DB
+	maxRetries          int    // Times a statement aborted by a deadlock or serialization failure is run again.
+	maxProducts         int64  // Maximum number of products, zero for no quota.
+	codeRequired        bool   // Whether the schema rejects products with an empty code.
+	codeGenerate        bool   // Whether the schema fills in the code of products saved without one.
+	codeCaseInsensitive bool   // Whether codes differing only in case are the same code.
+	codeUnique          bool   // Whether the schema rejects a code already used by another product.
+	lengthUnit          string // LengthUnitRunes or LengthUnitBytes.
 }
 
-// NewPgStorage creates a new instance of PgStorage.
+// NewPgStorage connects to the database and creates a PgStorage configured from the environment.
 func NewPgStorage() (*PgStorage, error) {
+	port, err := env.Int("DB_PORT", 5439)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := LoadPoolConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	postgresqlDbInfo := fmt.Sprintf("host=%s port=%d user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		"localhost", 5439, "apigo", "apigo", "apigo")
+		"password=%s dbname=%s sslmode=%s",
+		dsnValue(env.String("DB_HOST", "localhost")), port, dsnValue(env.String("DB_USER", "apigo")),
+		dsnValue(env.String("DB_PASSWORD", "apigo")), dsnValue(env.String("DB_NAME", "apigo")),
+		dsnValue(env.String("DB_SSLMODE", "disable")))
 
 	db, err := sql.Open("postgres", postgresqlDbInfo)
 	if err != nil {
 		return nil, err
 	}
+	pool.Apply(db)
 
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
 
-	return &PgStorage{db: db}, nil
+	o := NewPgStorageWithDB(db)
+	if err := o.loadSettings(); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// dsnValue quotes a value of a key/value connection string, so spaces and quotes in passwords survive.
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// NewPgStorageWithDB creates a PgStorage on an existing connection pool, with the default settings.
+// The environment isn't read, so callers wiring the pool themselves, or tests, control everything.
+func NewPgStorageWithDB(db *sql.DB) *PgStorage {
+	return &PgStorage{
+		db:           db,
+		maxRetries:   3,
+		codeRequired: true,
+		codeUnique:   true,
+		lengthUnit:   LengthUnitRunes,
+	}
+}
+
+// loadSettings overrides the settings of the storage with the environment variables that are set.
+func (o *PgStorage) loadSettings() error {
+	var err error
+
+	if o.maxRetries, err = env.Int("DB_DEADLOCK_RETRIES", o.maxRetries); err != nil {
+		return err
+	}
+
+	maxProducts, err := env.Int("PRODUCT_QUOTA", int(o.maxProducts))
+	if err != nil {
+		return err
+	}
+	o.maxProducts = int64(maxProducts)
+
+	if o.codeRequired, err = env.Bool("CODE_REQUIRED", o.codeRequired); err != nil {
+		return err
+	}
+
+	if o.codeGenerate, err = env.Bool("CODE_AUTO_GENERATE", o.codeGenerate); err != nil {
+		return err
+	}
+
+	if o.codeCaseInsensitive, err = env.Bool("CODE_CASE_INSENSITIVE", o.codeCaseInsensitive); err != nil {
+		return err
+	}
+
+	if o.codeUnique, err = env.Bool("CODE_UNIQUE", o.codeUnique); err != nil {
+		return err
+	}
+
+	o.lengthUnit, err = LoadLengthUnit()
+	return err
+}
+
+// Unique indexes on the product code, selected by CODE_CASE_INSENSITIVE.
+const (
+	uniqueCodeIndex      = "product_code_unique"
+	uniqueCodeLowerIndex = "product_code_unique_lower"
+)
+
+// initUniqueCode creates the unique code index in use and drops the other one.
+func (o *PgStorage) initUniqueCode() error {
+	keep, drop, column := uniqueCodeIndex, uniqueCodeLowerIndex, "code"
+	if o.codeCaseInsensitive {
+		keep, drop, column = uniqueCodeLowerIndex, uniqueCodeIndex, "lower(code)"
+	}
+	if !o.codeUnique {
+		keep, drop = "", uniqueCodeIndex+", "+uniqueCodeLowerIndex
+	}
+
+	if _, err := o.db.Exec("drop index if exists " + drop); err != nil {
+		return err
+	}
+	if keep == "" {
+		return nil
+	}
+
+	_, err := o.db.Exec("create unique index if not exists " + keep + " on product (" + column + ") where code <> ''")
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+		return fmt.Errorf("products share a code, fix them before enforcing CODE_UNIQUE; GET /getProducts?onlyDuplicates=true lists them: %w", err)
+	}
+	return err
+}
+
+// generateCodeFunction creates the trigger function giving products without a code one derived
+// from their ID, such as PRD-00000042. The ID is unique, but a product may have been given that
+// code explicitly, in which case a numeric suffix is added until the code is free.
+const generateCodeFunction = `
+	create or replace function product_generate_code() returns trigger as $$
+	declare
+		generated text := 'PRD-' || lpad(new.id::text, greatest(8, length(new.id::text)), '0');
+		suffix    int  := 1;
+	begin
+		new.code := generated;
+		while exists (select 1 from product where code = new.code and id <> new.id) loop
+			suffix := suffix + 1;
+			new.code := generated || '-' || suffix;
+		end loop;
+		return new;
+	end
+	$$ language plpgsql
+`
+
+// withRetry runs op again when Postgres aborts it because of a deadlock or a serialization failure.
+// op must be a complete transaction, it's run from the start on every attempt.
+func (o *PgStorage) withRetry(ctx context.Context, op func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !isConflict(err) || attempt > o.maxRetries {
+			return translateError(err)
+		}
+
+		slog.Warn("retrying statement aborted by a conflict", "attempt", attempt, "error", err.Error())
+		timer := time.NewTimer(time.Duration(attempt)*retryDelay + rand.N(retryDelay))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// translateError turns the Postgres errors the callers can act on into the errors of this package.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	if pqErr.Code == pgUndefinedTable {
+		slog.Error("a table is missing, run the schema initialization (PgStorage.Init) before serving requests", "error", err.Error())
+		return fmt.Errorf("%w: %s", ErrNotInitialized, pqErr.Message)
+	}
+	if pqErr.Code == pgStringTooLong || pqErr.Code == pgCheckViolation {
+		return fmt.Errorf("%w: %s", ErrInvalidData, pqErr.Message)
+	}
+	if pqErr.Code == pgUniqueViolation && (pqErr.Constraint == uniqueCodeIndex || pqErr.Constraint == uniqueCodeLowerIndex) {
+		return fmt.Errorf("%w: %s", ErrDuplicateCode, pqErr.Detail)
+	}
+
+	return err
+}
+
+// isConflict reports whether err is a deadlock or serialization failure reported by Postgres.
+func isConflict(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pgDeadlockDetected || pqErr.Code == pgSerializationFailure
 }
 
 // Init initializes the database schema.
@@ -69,35 +461,450 @@ func (o *PgStorage) Init() error {
 			createdAt timestamp
 		)
     `)
+	if err != nil {
+		return err
+	}
+
+	if err = o.ensureColumns("product", productColumnsSchema); err != nil {
+		return err
+	}
+
+	// The constraint follows CODE_REQUIRED on every start. It's not validated against existing
+	// rows, so products created while codes were optional don't prevent the server from starting.
+	if _, err = o.db.Exec("alter table product drop constraint if exists product_code_required"); err != nil {
+		return err
+	}
+	if o.codeRequired {
+		if _, err = o.db.Exec("alter table product add constraint product_code_required check (code <> '') not valid"); err != nil {
+			return err
+		}
+	}
+
+	// Like the code constraint, the trigger filling in empty codes follows CODE_AUTO_GENERATE on every
+	// start. It runs before the constraint is checked, so generated codes satisfy it.
+	if _, err = o.db.Exec("drop trigger if exists product_generate_code on product"); err != nil {
+		return err
+	}
+	if o.codeGenerate {
+		if _, err = o.db.Exec(generateCodeFunction); err != nil {
+			return err
+		}
+		if _, err = o.db.Exec(`
+			create trigger product_generate_code before insert or update of code on product
+				for each row when (new.code is null or new.code = '') execute function product_generate_code()
+		`); err != nil {
+			return err
+		}
+	}
+
+	// Case-insensitive lookups go through lower(code), which a plain index on code can't serve.
+	if o.codeCaseInsensitive {
+		if _, err = o.db.Exec("create index if not exists product_code_lower on product (lower(code))"); err != nil {
+			return err
+		}
+	} else if _, err = o.db.Exec("drop index if exists product_code_lower"); err != nil {
+		return err
+	}
+
+	// Unique codes follow CODE_UNIQUE and CODE_CASE_INSENSITIVE on every start. Empty codes are
+	// exempt, since they mean no code. Unlike the checks, the index can't skip existing rows.
+	if err = o.initUniqueCode(); err != nil {
+		return err
+	}
+
+	// varchar(50) already limits characters. Byte limits add a check on the encoded length.
+	if _, err = o.db.Exec("alter table product drop constraint if exists product_length_bytes"); err != nil {
+		return err
+	}
+	if o.lengthUnit == LengthUnitBytes {
+		if _, err = o.db.Exec("alter table product add constraint product_length_bytes check (octet_length(name) <= 50 and octet_length(code) <= 50) not valid"); err != nil {
+			return err
+		}
+	}
+
+	_, err = o.db.Exec(`
+		create table if not exists product_locks
+		(
+			productId bigint primary key references product (id) on delete cascade,
+			holder    varchar(100) not null,
+			expiresAt timestamp    not null
+		)
+    `)
 
 	return err
 }
 
 // CreateProduct inserts a new product into the database.
-func (o *PgStorage) CreateProduct(p *Product) (*Product, error) {
-	_, err := o.db.Exec("insert into product (name, code, createdAt) values($1, $2, $3)", p.Name, p.Code, p.CreatedAt)
+func (o *PgStorage) CreateProduct(ctx context.Context, p *Product) (*Product, error) {
+	var lastInsertId int64
+	var code string
+	err := o.withTx(ctx, func(tx *sql.Tx) error {
+		if err := o.checkQuota(ctx, tx, 1); err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(ctx,
+			"insert into product (name, code, createdAt, updatedAt, status) values($1, $2, $3, $3, $4) returning id, code",
+			p.Name, p.Code, p.CreatedAt, p.Status,
+		).Scan(&lastInsertId, &code)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var lastInsertId int64
-	err = o.db.QueryRow("SELECT lastval()").Scan(&lastInsertId)
+	p.Id = lastInsertId
+	p.Code = code
+	p.UpdatedAt = p.CreatedAt
+
+	return p, nil
+}
+
+// CreateProductIfCodeAbsent inserts the product unless a product with the same code exists, in which
+// case ErrCodeExists is returned and nothing is modified. Codes are compared ignoring case when
+// CODE_CASE_INSENSITIVE is set. Conditional creations of the same code are serialized with an
+// advisory lock, so two of them can't both succeed.
+func (o *PgStorage) CreateProductIfCodeAbsent(ctx context.Context, p *Product) (*Product, error) {
+	key, match := "$1", "code = $2"
+	if o.codeCaseInsensitive {
+		key, match = "lower($1)", "lower(code) = lower($2)"
+	}
+
+	var id int64
+	var code string
+	err := o.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock(hashtext("+key+"))", p.Code); err != nil {
+			return err
+		}
+
+		if err := o.checkQuota(ctx, tx, 1); err != nil {
+			return err
+		}
+
+		err := tx.QueryRowContext(ctx, `
+			insert into product (name, code, createdAt, updatedAt, status)
+			select $1, $2, $3, $3, $4 where not exists (select 1 from product where `+match+`)
+			returning id, code
+		`, p.Name, p.Code, p.CreatedAt, p.Status).Scan(&id, &code)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCodeExists
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	p.Id = lastInsertId
+	p.Id = id
+	p.Code = code
+	p.UpdatedAt = p.CreatedAt
 
 	return p, nil
 }
 
-// GetProducts retrieves all products from the database.
-func (o *PgStorage) GetProducts() ([]*Product, error) {
-	rows, err := o.db.Query("select * from product")
+// checkQuota returns ErrQuotaExceeded when adding n products would go beyond the quota.
+// Creations are serialized with an advisory lock held until tx ends, so concurrent
+// transactions can't both pass the check.
+func (o *PgStorage) checkQuota(ctx context.Context, tx *sql.Tx, n int) error {
+	if o.maxProducts <= 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock(hashtext('product.quota'))"); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.QueryRowContext(ctx, "select count(*) from product").Scan(&count); err != nil {
+		return err
+	}
+	if count+int64(n) > o.maxProducts {
+		return ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// withTx runs op inside a transaction, committed when op succeeds and rolled back otherwise.
+// The whole transaction is run again on deadlocks and serialization failures.
+func (o *PgStorage) withTx(ctx context.Context, op func(tx *sql.Tx) error) error {
+	return o.withRetry(ctx, func() error {
+		tx, err := o.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer rollback(tx)
+
+		if err := op(tx); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// rollback aborts the transaction unless it was already committed.
+func rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		slog.Error(err.Error())
+	}
+}
+
+// CreateProducts inserts several products into the database with a single multi-row insert.
+// Products whose ID was drawn with ReserveProductId are inserted with it. A product rejected for its
+// code is named by a RowError.
+func (o *PgStorage) CreateProducts(ctx context.Context, products []*Product) ([]*Product, error) {
+	if len(products) == 0 {
+		return products, nil
+	}
+
+	values := make([]string, len(products))
+	args := make([]any, 0, len(products)*5)
+	reserved := make(map[int64]bool)
+	for i, p := range products {
+		values[i] = fmt.Sprintf("(coalesce($%d, nextval(pg_get_serial_sequence('product', 'id'))), $%d, $%d, $%d, $%d, $%d)", i*5+1, i*5+2, i*5+3, i*5+4, i*5+4, i*5+5)
+		var id any
+		if p.idReserved {
+			id = p.Id
+			reserved[p.Id] = true
+		}
+		args = append(args, id, p.Name, p.Code, p.CreatedAt, p.Status)
+	}
+
+	query := "insert into product (id, name, code, createdAt, updatedAt, status) values " + strings.Join(values, ", ") + " returning id, code"
+	var created []*Product
+	err := o.withTx(ctx, func(tx *sql.Tx) error {
+		if err := o.checkQuota(ctx, tx, len(products)); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		defer func(rows *sql.Rows) {
+			err := rows.Close()
+			if err != nil {
+				slog.Error(err.Error())
+			}
+		}(rows)
+
+		created = make([]*Product, 0, len(products))
+		for rows.Next() {
+			c := new(Product)
+			if err := rows.Scan(&c.Id, &c.Code); err != nil {
+				return err
+			}
+			created = append(created, c)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, o.rejectedRow(products, err)
+	}
+
+	// The serial values are drawn in the order of the VALUES list, while the
+	// order of the returned rows is not guaranteed.
+	slices.SortFunc(created, func(a, b *Product) int { return cmp.Compare(a.Id, b.Id) })
+	codes := make(map[int64]string, len(created))
+	drawn := make([]int64, 0, len(created))
+	for _, c := range created {
+		codes[c.Id] = c.Code
+		if !reserved[c.Id] {
+			drawn = append(drawn, c.Id)
+		}
+	}
+	for _, p := range products {
+		if !p.idReserved {
+			p.Id, drawn = drawn[0], drawn[1:]
+		}
+		p.Code = codes[p.Id]
+		p.UpdatedAt = p.CreatedAt
+	}
+
+	return products, nil
+}
+
+// duplicateKeyDetail extracts the value of the key of a unique violation from its detail,
+// such as Key (code)=(DSK-1) already exists.
+var duplicateKeyDetail = regexp.MustCompile(`^Key \(.*\)=\((.*)\) already exists`)
+
+// rejectedRow translates the error of a multi-row insert, wrapping a duplicate code in a RowError naming
+// the product that Postgres rejected. When several products of the batch share the code, the second one
+// is rejected, since the first was inserted without a conflict.
+func (o *PgStorage) rejectedRow(products []*Product, err error) error {
+	translated := translateError(err)
+	var pqErr *pq.Error
+	if !errors.Is(translated, ErrDuplicateCode) || !errors.As(err, &pqErr) {
+		return translated
+	}
+	match := duplicateKeyDetail.FindStringSubmatch(pqErr.Detail)
+	if match == nil {
+		return translated
+	}
+
+	sharing := make([]int, 0)
+	for i, p := range products {
+		if p.Code == match[1] || (o.codeCaseInsensitive && strings.EqualFold(p.Code, match[1])) {
+			sharing = append(sharing, i)
+		}
+	}
+	if len(sharing) == 0 {
+		return translated
+	}
+	rejected := sharing[min(1, len(sharing)-1)]
+	return &RowError{Index: rejected, Code: products[rejected].Code, Err: translated}
+}
+
+// ReserveProductId draws the ID of a product to be created later from the sequence of the product table.
+func (o *PgStorage) ReserveProductId(ctx context.Context) (int64, error) {
+	var id int64
+	if err := o.db.QueryRowContext(ctx, "select nextval(pg_get_serial_sequence('product', 'id'))").Scan(&id); err != nil {
+		return 0, translateError(err)
+	}
+	return id, nil
+}
+
+// GetProducts retrieves a page of the products matching the filter from the database, ordered by ID,
+// along with the number of products matching the filter across all pages.
+func (o *PgStorage) GetProducts(ctx context.Context, filter ProductFilter, page Page) ([]*Product, int64, error) {
+	query, args := BuildProductsQuery(filter, page)
+	products, err := o.queryProducts(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := o.CountProducts(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return products, total, nil
+}
+
+// CountProducts returns the number of products matching the filter.
+func (o *PgStorage) CountProducts(ctx context.Context, filter ProductFilter) (int64, error) {
+	where, args := productsWhere(filter)
+	var total int64
+	if err := o.db.QueryRowContext(ctx, "select count(*) from product"+where, args...).Scan(&total); err != nil {
+		return 0, translateError(err)
+	}
+	return total, nil
+}
+
+// likeEscaper escapes the wildcards of like patterns, along with the default escape character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SearchProducts retrieves a page of the products whose name contains query, ignoring case, ordered by ID.
+// Wildcards in query match literally. The sort of the page is ignored.
+func (o *PgStorage) SearchProducts(ctx context.Context, query string, page Page) ([]*Product, error) {
+	return o.queryProducts(ctx, "select "+productColumns+" from product where name ilike '%' || $1 || '%' order by id limit $2 offset $3",
+		likeEscaper.Replace(query), page.Limit, page.Offset)
+}
+
+// BuildProductsQuery builds the parameterized listing query of the filter and page, as run by PgStorage.GetProducts.
+func BuildProductsQuery(filter ProductFilter, page Page) (string, []any) {
+	where, args := productsWhere(filter)
+
+	query := "select " + productColumns + " from product" + where + productsOrder(filter, page)
+	if page.Limit > 0 {
+		args = append(args, page.Limit)
+		query += fmt.Sprintf(" limit $%d", len(args))
+	}
+	if page.Offset > 0 {
+		args = append(args, page.Offset)
+		query += fmt.Sprintf(" offset $%d", len(args))
+	}
+
+	return query, args
+}
+
+// productsOrder builds the order by clause of the page. Products are ordered by ID, or by code when
+// only duplicates are listed, unless Page.Sort picks another field; ties are then ordered by ID.
+func productsOrder(filter ProductFilter, page Page) string {
+	field, descending := strings.CutPrefix(page.Sort, "-")
+	direction := ""
+	if descending {
+		direction = " desc"
+	}
+
+	switch {
+	case page.Sort == "" && filter.OnlyDuplicates:
+		return " order by code, id"
+	case page.Sort == "" || field == "id":
+		return " order by id" + direction
+	default:
+		return " order by " + sortColumns[field] + direction + ", id"
+	}
+}
+
+// productsWhere builds the parameterized where clause of the filter, empty when it doesn't filter.
+func productsWhere(filter ProductFilter) (string, []any) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.OnlyDuplicates {
+		conditions = append(conditions, "code in (select code from product group by code having count(*) > 1)")
+	}
+	if filter.IdFrom > 0 {
+		args = append(args, filter.IdFrom)
+		conditions = append(conditions, fmt.Sprintf("id >= $%d", len(args)))
+	}
+	if filter.IdTo > 0 {
+		args = append(args, filter.IdTo)
+		conditions = append(conditions, fmt.Sprintf("id <= $%d", len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " where " + strings.Join(conditions, " and "), args
+}
+
+// GetNameCollisions retrieves the products whose name is shared by products with a different code, grouped by name.
+func (o *PgStorage) GetNameCollisions(ctx context.Context) ([]*NameCollision, error) {
+	products, err := o.queryProducts(ctx, `
+		select `+productColumns+` from product
+		where name in (select name from product group by name having count(distinct code) > 1)
+		order by name, id
+	`)
 	if err != nil {
 		return nil, err
 	}
 
+	collisions := make([]*NameCollision, 0)
+	for _, p := range products {
+		if len(collisions) == 0 || collisions[len(collisions)-1].Name != p.Name {
+			collisions = append(collisions, &NameCollision{Name: p.Name})
+		}
+		collision := collisions[len(collisions)-1]
+		collision.Products = append(collision.Products, p)
+	}
+
+	return collisions, nil
+}
+
+// GetRandomProducts retrieves up to n products picked at random.
+//
+// order by random() reads and sorts the whole table, which is fine for catalogs of a few
+// hundred thousand rows. For larger tables, "tablesample system_rows(n)" from the tsm_system_rows
+// extension is much cheaper, at the cost of returning rows clustered in the same pages.
+func (o *PgStorage) GetRandomProducts(ctx context.Context, n int) ([]*Product, error) {
+	return o.queryProducts(ctx, "select "+productColumns+" from product order by random() limit $1", n)
+}
+
+// queryProducts runs a query selecting productColumns, and scans every row into a Product.
+func (o *PgStorage) queryProducts(ctx context.Context, query string, args ...any) ([]*Product, error) {
+	rows, err := o.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
 	defer func(rows *sql.Rows) {
 		err := rows.Close()
 		if err != nil {
@@ -108,49 +915,426 @@ func (o *PgStorage) GetProducts() ([]*Product, error) {
 	products := make([]*Product, 0)
 
 	for rows.Next() {
-		product := &Product{}
-		if err := rows.Scan(&product.Id, &product.Name, &product.Code, &product.CreatedAt); err != nil {
+		product, err := scanProduct(rows)
+		if err != nil {
 			return nil, err
 		}
 		products = append(products, product)
 	}
 
-	return products, nil
+	return products, rows.Err()
+}
+
+// scanProduct scans a row of productColumns into a Product.
+func scanProduct(row interface{ Scan(...any) error }) (*Product, error) {
+	p := new(Product)
+	if err := row.Scan(&p.Id, &p.Name, &p.Code, &p.CreatedAt, &p.UpdatedAt, &p.Status, &p.Views); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-// GetProductById retrieves a product from the database by its ID.
-func (o *PgStorage) GetProductById(id int64) (*Product, error) {
-	rows, err := o.db.Query("select * from p where id=$1", id)
+// GetProductById retrieves a product from the database by its ID. ErrNotFound is returned when it doesn't exist.
+func (o *PgStorage) GetProductById(ctx context.Context, id int64) (*Product, error) {
+	p, err := scanProduct(o.db.QueryRowContext(ctx, "select "+productColumns+" from product where id=$1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return p, nil
+}
+
+// GetProductByCode retrieves the product with the given code, ignoring case when CODE_CASE_INSENSITIVE
+// is set. With CODE_UNIQUE, the default, at most one product has it; otherwise products may share it,
+// and the oldest of them is returned. ErrNotFound is returned when no product has the code.
+func (o *PgStorage) GetProductByCode(ctx context.Context, code string) (*Product, error) {
+	match := "code = $1"
+	if o.codeCaseInsensitive {
+		match = "lower(code) = lower($1)"
+	}
+
+	p, err := scanProduct(o.db.QueryRowContext(ctx, "select "+productColumns+" from product where "+match+" order by id limit 1", code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return p, nil
+}
+
+// GetProductsByIds retrieves the products with the given IDs. IDs without a product are skipped.
+func (o *PgStorage) GetProductsByIds(ctx context.Context, ids []int64) ([]*Product, error) {
+	return o.queryProducts(ctx, "select "+productColumns+" from product where id = any($1)", pq.Array(ids))
+}
+
+// UpdateProduct updates the name and code of an existing product in the database, and returns the
+// product as stored. An empty status keeps the current one, the creation time is never modified and
+// the modification time is set to now. A non-zero UpdatedAt makes the update conditional: ErrModified is
+// returned when the product was modified or deleted since then.
+func (o *PgStorage) UpdateProduct(ctx context.Context, p *Product) (*Product, error) {
+	query := "update product set name=$1, code=$2, status=coalesce(nullif($3, ''), status), updatedAt=$5 where id=$4"
+	args := []any{p.Name, p.Code, p.Status, p.Id, time.Now().UTC()}
+	if !p.UpdatedAt.IsZero() {
+		query += " and updatedAt=$6"
+		args = append(args, p.UpdatedAt)
+	}
+
+	var updated *Product
+	err := o.withRetry(ctx, func() error {
+		var err error
+		updated, err = scanProduct(o.db.QueryRowContext(ctx, query+" returning "+productColumns, args...))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		if !p.UpdatedAt.IsZero() {
+			return nil, ErrModified
+		}
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			slog.Error(err.Error())
+	return updated, nil
+}
+
+// PatchProduct sets the fields of patch that aren't nil on an existing product, and returns the product
+// as stored. Only those columns are written, so concurrent changes to the other ones are kept. The
+// modification time is set to now. ErrNotFound is returned when the product doesn't exist.
+func (o *PgStorage) PatchProduct(ctx context.Context, id int64, patch ProductPatch) (*Product, error) {
+	columns := []struct {
+		name  string
+		value *string
+	}{{"name", patch.Name}, {"code", patch.Code}, {"status", patch.Status}}
+
+	sets := []string{"updatedAt=$1"}
+	args := []any{time.Now().UTC()}
+	for _, column := range columns {
+		if column.value != nil {
+			args = append(args, *column.value)
+			sets = append(sets, fmt.Sprintf("%s=$%d", column.name, len(args)))
 		}
-	}(rows)
+	}
+	args = append(args, id)
+	query := fmt.Sprintf("update product set %s where id=$%d returning %s", strings.Join(sets, ", "), len(args), productColumns)
 
-	if !rows.Next() {
-		return nil, fmt.Errorf("p with ID %d not found", id)
+	var patched *Product
+	err := o.withRetry(ctx, func() error {
+		var err error
+		patched, err = scanProduct(o.db.QueryRowContext(ctx, query, args...))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, translateError(err)
 	}
 
-	p := new(Product)
+	return patched, nil
+}
 
-	if err = rows.Scan(&p.Id, &p.Name, &p.Code, &p.CreatedAt); err != nil {
+// SetProductStatus moves a product to status, following statusTransitions. Setting the current status
+// again is a no-op, any other transition not listed returns ErrInvalidTransition.
+func (o *PgStorage) SetProductStatus(ctx context.Context, id int64, status string) (*Product, error) {
+	var p *Product
+	err := o.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		p, err = scanProduct(tx.QueryRowContext(ctx, "select "+productColumns+" from product where id=$1 for update", id))
+		if err != nil {
+			return err
+		}
+		if p.Status == status {
+			return nil
+		}
+		if !slices.Contains(statusTransitions[p.Status], status) {
+			return ErrInvalidTransition
+		}
+
+		p.Status = status
+		p.UpdatedAt = time.Now().UTC()
+		_, err = tx.ExecContext(ctx, "update product set status=$1, updatedAt=$2 where id=$3", status, p.UpdatedAt, id)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return p, nil
 }
 
-// UpdateProduct updates an existing product in the database.
-func (o *PgStorage) UpdateProduct(p *Product) (*Product, error) {
-	_, err := o.db.Exec("update product set name=$1, code=$2, createdAt=$3 where id=$4", p.Name, p.Code, p.CreatedAt, p.Id)
+// DeleteProduct removes a product, along with its lock. ErrNotFound is returned when it doesn't exist.
+func (o *PgStorage) DeleteProduct(ctx context.Context, id int64) error {
+	var affected int64
+	err := o.withRetry(ctx, func() error {
+		result, err := o.db.ExecContext(ctx, "delete from product where id = $1", id)
+		if err != nil {
+			return err
+		}
+		affected, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return translateError(err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// MergeProducts merges the product mergeId into keepId in a transaction: the views of mergeId are added
+// to keepId, then mergeId is deleted, along with its lock. The name, code and status of keepId are kept,
+// and its modification time is set to now. ErrNotFound is returned unless both products exist.
+func (o *PgStorage) MergeProducts(ctx context.Context, keepId, mergeId int64) (*Product, error) {
+	var kept *Product
+	err := o.withTx(ctx, func(tx *sql.Tx) error {
+		// Both rows are locked in ID order, so merges involving the same products can't deadlock.
+		var found int
+		if err := tx.QueryRowContext(ctx, "select count(*) from (select id from product where id = any($1) order by id for update) locked",
+			pq.Array([]int64{keepId, mergeId})).Scan(&found); err != nil {
+			return err
+		}
+		if found != 2 {
+			return ErrNotFound
+		}
+
+		var views int64
+		if err := tx.QueryRowContext(ctx, "delete from product where id = $1 returning view_count", mergeId).Scan(&views); err != nil {
+			return err
+		}
+
+		var err error
+		kept, err = scanProduct(tx.QueryRowContext(ctx, "update product set view_count = view_count + $1, updatedAt = $2 where id = $3 returning "+productColumns,
+			views, time.Now().UTC(), keepId))
+		return err
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return kept, nil
+}
+
+// AddProductViews adds the given number of views to each product, in a single statement.
+// Products deleted in between are skipped.
+func (o *PgStorage) AddProductViews(ctx context.Context, views map[int64]int64) error {
+	if len(views) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(views))
+	counts := make([]int64, 0, len(views))
+	for id, count := range views {
+		ids = append(ids, id)
+		counts = append(counts, count)
+	}
+
+	return translateError(o.withRetry(ctx, func() error {
+		_, err := o.db.ExecContext(ctx, `
+			update product set view_count = view_count + v.count
+			from unnest($1::bigint[], $2::bigint[]) as v(id, count)
+			where product.id = v.id
+		`, pq.Array(ids), pq.Array(counts))
+		return err
+	}))
+}
+
+// ClaimProducts marks up to n unclaimed products as claimed by worker and returns them, ordered by ID.
+// Rows being claimed by another worker are skipped rather than waited for, so concurrent workers
+// never get the same product and don't block each other.
+func (o *PgStorage) ClaimProducts(ctx context.Context, worker string, n int) ([]*Product, error) {
+	if n <= 0 {
+		return make([]*Product, 0), nil
+	}
+
+	var products []*Product
+	err := o.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			update product set claimedBy = $1, claimedAt = $2
+			where id in (select id from product where claimedBy is null order by id limit $3 for update skip locked)
+			returning `+productColumns, worker, time.Now().UTC(), n)
+		if err != nil {
+			return err
+		}
+		products = make([]*Product, 0, n)
+		for rows.Next() {
+			p, err := scanProduct(rows)
+			if err != nil {
+				_ = rows.Close()
+				return err
+			}
+			products = append(products, p)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	slices.SortFunc(products, func(a, b *Product) int { return cmp.Compare(a.Id, b.Id) })
+	return products, nil
+}
+
+// Ping checks that the database can be reached.
+func (o *PgStorage) Ping(ctx context.Context) error {
+	return o.db.PingContext(ctx)
+}
+
+// Close closes the connection pool. The storage can't be used anymore afterwards.
+func (o *PgStorage) Close() error {
+	return o.db.Close()
+}
+
+// ProductExists reports whether a product with the given ID exists in the database.
+func (o *PgStorage) ProductExists(ctx context.Context, id int64) (bool, error) {
+	var exists bool
+	err := o.db.QueryRowContext(ctx, "select exists(select 1 from product where id=$1)", id).Scan(&exists)
+	if err != nil {
+		return false, translateError(err)
+	}
+
+	return exists, nil
+}
+
+// LockProduct locks the product for holder during ttl. A holder locking again extends its lock.
+// ErrProductLocked is returned while another holder owns a lock that hasn't expired.
+func (o *PgStorage) LockProduct(ctx context.Context, id int64, holder string, ttl time.Duration) (*ProductLock, error) {
+	now := time.Now().UTC()
+	lock := new(ProductLock)
+	err := o.withRetry(ctx, func() error {
+		return o.db.QueryRowContext(ctx, `
+			insert into product_locks (productId, holder, expiresAt) values ($1, $2, $3)
+			on conflict (productId) do update set holder = excluded.holder, expiresAt = excluded.expiresAt
+			where product_locks.holder = excluded.holder or product_locks.expiresAt <= $4
+			returning productId, holder, expiresAt
+		`, id, holder, now.Add(ttl), now).Scan(&lock.ProductId, &lock.Holder, &lock.ExpiresAt)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProductLocked
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return p, nil
+	return lock, nil
+}
+
+// GetProductLock retrieves the lock of a product, or nil when it isn't locked or its lock expired.
+func (o *PgStorage) GetProductLock(ctx context.Context, id int64) (*ProductLock, error) {
+	lock := new(ProductLock)
+	err := o.db.QueryRowContext(ctx, "select productId, holder, expiresAt from product_locks where productId=$1 and expiresAt > $2", id, time.Now().UTC()).
+		Scan(&lock.ProductId, &lock.Holder, &lock.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return lock, nil
+}
+
+// NormalizeCodes trims and uppercases every product code in a single transaction. A product whose
+// normalized code is already used by another product keeps its code and is reported as a collision.
+// The table is locked against writes meanwhile, so no collision can appear behind its back.
+func (o *PgStorage) NormalizeCodes(ctx context.Context) (*CodeNormalization, error) {
+	now := time.Now().UTC()
+	var result *CodeNormalization
+	err := o.withTx(ctx, func(tx *sql.Tx) error {
+		result = &CodeNormalization{Collisions: make([]*Product, 0), Normalized: make([]*Product, 0)}
+
+		if _, err := tx.ExecContext(ctx, "lock table product in share row exclusive mode"); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, "select "+productColumns+" from product order by id")
+		if err != nil {
+			return err
+		}
+		products := make([]*Product, 0)
+		for rows.Next() {
+			p, err := scanProduct(rows)
+			if err != nil {
+				_ = rows.Close()
+				return err
+			}
+			products = append(products, p)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// Codes already in their normal form are kept first, so they win over codes that would become equal.
+		taken := make(map[string]bool)
+		for _, p := range products {
+			if normalizeCode(p.Code) == p.Code {
+				taken[p.Code] = true
+			}
+		}
+
+		for _, p := range products {
+			code := normalizeCode(p.Code)
+			if code == p.Code {
+				continue
+			}
+			if code != "" && taken[code] {
+				result.Collisions = append(result.Collisions, p)
+				continue
+			}
+
+			normalized, err := scanProduct(tx.QueryRowContext(ctx, "update product set code = $1, updatedAt = $2 where id = $3 returning "+productColumns, code, now, p.Id))
+			if err != nil {
+				return err
+			}
+			taken[code] = true
+			result.Changed++
+			result.Normalized = append(result.Normalized, normalized)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return result, nil
+}
+
+// ReplaceSubstring replaces every occurrence of from with to in the given field of all products,
+// in a single statement, and returns the products changed, as stored. field must be one of
+// ReplaceableFields, since it's part of the query text.
+func (o *PgStorage) ReplaceSubstring(ctx context.Context, field, from, to string) ([]*Product, error) {
+	if !slices.Contains(ReplaceableFields, field) {
+		return nil, fmt.Errorf("field %q can't be replaced", field)
+	}
+
+	var replaced []*Product
+	err := o.withRetry(ctx, func() error {
+		var err error
+		replaced, err = o.queryProducts(ctx, fmt.Sprintf("update product set %[1]s = replace(%[1]s, $1, $2), updatedAt = $3 where strpos(%[1]s, $1) > 0 returning %[2]s", field, productColumns), from, to, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return replaced, nil
+}
+
+// normalizeCode returns the canonical form of a product code.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
 }
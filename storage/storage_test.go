@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// newMockStorage creates a PgStorage over a sqlmock connection, failing the test on unmet expectations.
+func newMockStorage(t *testing.T) (*PgStorage, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = db.Close()
+	})
+	return NewPgStorageWithDB(db), mock
+}
+
+// productRow is the row of productColumns of a product.
+func productRow(p *Product) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "code", "createdat", "updatedat", "status", "view_count"}).
+		AddRow(p.Id, p.Name, p.Code, p.CreatedAt, p.UpdatedAt, p.Status, p.Views)
+}
+
+func TestWithRetryRunsDeadlockedStatementsAgain(t *testing.T) {
+	db, mock := newMockStorage(t)
+	deadlock := &pq.Error{Code: pgDeadlockDetected, Message: "deadlock detected"}
+	stored := &Product{Id: 7, Name: "Desk", Code: "DSK-1", CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Status: StatusActive}
+
+	mock.ExpectQuery("update product set name").WillReturnError(deadlock)
+	mock.ExpectQuery("update product set name").WillReturnError(deadlock)
+	mock.ExpectQuery("update product set name").WillReturnRows(productRow(stored))
+
+	updated, err := db.UpdateProduct(context.Background(), &Product{Id: 7, Name: "Desk", Code: "DSK-1"})
+	if err != nil {
+		t.Fatalf("the update should succeed once the deadlock is gone: %v", err)
+	}
+	if updated.Id != 7 || updated.Name != "Desk" {
+		t.Errorf("unexpected product: %+v", updated)
+	}
+}
+
+func TestWithRetryGivesUpAfterTheConfiguredRetries(t *testing.T) {
+	db, mock := newMockStorage(t)
+	db.maxRetries = 1
+	deadlock := &pq.Error{Code: pgDeadlockDetected, Message: "deadlock detected"}
+
+	mock.ExpectQuery("update product set name").WillReturnError(deadlock)
+	mock.ExpectQuery("update product set name").WillReturnError(deadlock)
+
+	_, err := db.UpdateProduct(context.Background(), &Product{Id: 7, Name: "Desk", Code: "DSK-1"})
+	if !isConflict(err) {
+		t.Fatalf("expected the deadlock once the retries are exhausted, got %v", err)
+	}
+}
+
+func TestRejectedRowNamesTheProductOfTheDuplicateKey(t *testing.T) {
+	products := []*Product{NewProduct("Chair", "CHR-1"), NewProduct("Desk", "dsk-1"), NewProduct("Desk", "DSK-1")}
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		err             *pq.Error
+		index           int
+	}{
+		{"stored code", false, &pq.Error{Code: pgUniqueViolation, Constraint: uniqueCodeIndex, Detail: "Key (code)=(DSK-1) already exists."}, 2},
+		{"second of the batch", true, &pq.Error{Code: pgUniqueViolation, Constraint: uniqueCodeLowerIndex, Detail: "Key (lower(code::text))=(dsk-1) already exists."}, 2},
+		{"stored code ignoring case", true, &pq.Error{Code: pgUniqueViolation, Constraint: uniqueCodeLowerIndex, Detail: "Key (lower(code::text))=(chr-1) already exists."}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := &PgStorage{codeCaseInsensitive: test.caseInsensitive}
+
+			var rejected *RowError
+			err := db.rejectedRow(products, test.err)
+			if !errors.As(err, &rejected) || !errors.Is(err, ErrDuplicateCode) {
+				t.Fatalf("expected a RowError of a duplicate code, got %v", err)
+			}
+			if rejected.Index != test.index || rejected.Code != products[test.index].Code {
+				t.Errorf("expected product %d to be rejected, got %+v", test.index, rejected)
+			}
+		})
+	}
+}
+
+func TestClaimProductsSkipsRowsLockedByOtherClaimers(t *testing.T) {
+	db, mock := newMockStorage(t)
+	claimed := &Product{Id: 3, Name: "Desk", Code: "DSK-3", CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC(), Status: StatusActive}
+
+	// Rows locked by a concurrent claimer must be skipped, not waited for and claimed again.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`claimedBy is null order by id limit \$3 for update skip locked`).
+		WithArgs("worker-1", sqlmock.AnyArg(), 2).
+		WillReturnRows(productRow(claimed))
+	mock.ExpectCommit()
+
+	products, err := db.ClaimProducts(context.Background(), "worker-1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].Id != 3 {
+		t.Errorf("unexpected products: %+v", products)
+	}
+}
+
+func TestCanceledContextStopsTheQuery(t *testing.T) {
+	storage, mock := newMockStorage(t)
+	mock.ExpectQuery("select .* from product where id=").
+		WithArgs(int64(1)).
+		WillDelayFor(5 * time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := storage.GetProductById(ctx, 1)
+	// sqlmock stands in for the driver, which reports the canceled statement with an error of its own.
+	if !errors.Is(err, sqlmock.ErrCancelled) {
+		t.Fatalf("expected the query to be canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the query to stop once canceled, it took %s", elapsed)
+	}
+}
+
+func TestCreateProductRejectsProductsBeyondTheQuota(t *testing.T) {
+	db, mock := newMockStorage(t)
+	db.maxProducts = 2
+
+	for count := range 2 {
+		mock.ExpectBegin()
+		mock.ExpectExec("pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("select count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+		mock.ExpectQuery("insert into product").WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(count+1, "DSK-1"))
+		mock.ExpectCommit()
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("select count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	for range 2 {
+		if _, err := db.CreateProduct(context.Background(), NewProduct("Desk", "DSK-1")); err != nil {
+			t.Fatalf("products within the quota should be created: %v", err)
+		}
+	}
+	if _, err := db.CreateProduct(context.Background(), NewProduct("Desk", "DSK-1")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+}
+
+func TestCreateProductReturnsTheCodeGeneratedByTheDatabase(t *testing.T) {
+	db, mock := newMockStorage(t)
+	db.codeGenerate = true
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("insert into product").
+		WithArgs("Desk", "", sqlmock.AnyArg(), StatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(42, "PRD-00000042"))
+	mock.ExpectCommit()
+
+	p, err := db.CreateProduct(context.Background(), NewProduct("Desk", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Id != 42 || p.Code != "PRD-00000042" {
+		t.Errorf("expected the generated code of product 42, got %d %q", p.Id, p.Code)
+	}
+}
+
+func TestCaseInsensitiveCodesShareTheUniqueIndex(t *testing.T) {
+	tests := []struct {
+		caseInsensitive bool
+		drop, create    string
+		lookup          string
+	}{
+		{true, uniqueCodeIndex, uniqueCodeLowerIndex + ` on product \(lower\(code\)\)`, `where lower\(code\) = lower\(\$1\)`},
+		{false, uniqueCodeLowerIndex, uniqueCodeIndex + ` on product \(code\)`, `where code = \$1`},
+	}
+
+	for _, test := range tests {
+		db, mock := newMockStorage(t)
+		db.codeCaseInsensitive = test.caseInsensitive
+
+		mock.ExpectExec("drop index if exists " + test.drop).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("create unique index if not exists " + test.create).WillReturnResult(sqlmock.NewResult(0, 0))
+		if err := db.initUniqueCode(); err != nil {
+			t.Fatal(err)
+		}
+
+		stored := &Product{Id: 1, Name: "Desk", Code: "abc", Status: StatusActive}
+		mock.ExpectQuery(test.lookup).WithArgs("ABC").WillReturnRows(productRow(stored))
+		if _, err := db.GetProductByCode(context.Background(), "ABC"); err != nil {
+			t.Fatalf("case insensitive %v: %v", test.caseInsensitive, err)
+		}
+	}
+}
+
+func TestCodesDifferingInCaseCollideOnTheLowerIndex(t *testing.T) {
+	db, mock := newMockStorage(t)
+	db.codeCaseInsensitive = true
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("insert into product").WillReturnError(&pq.Error{
+		Code:       pgUniqueViolation,
+		Constraint: uniqueCodeLowerIndex,
+		Detail:     "Key (lower(code::text))=(abc) already exists.",
+	})
+	mock.ExpectRollback()
+
+	if _, err := db.CreateProduct(context.Background(), NewProduct("Desk", "ABC")); !errors.Is(err, ErrDuplicateCode) {
+		t.Fatalf("expected ErrDuplicateCode, got %v", err)
+	}
+}
+
+func TestDeleteProductWithoutAffectedRowsIsNotFound(t *testing.T) {
+	db, mock := newMockStorage(t)
+	mock.ExpectExec("delete from product where id").WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("delete from product where id").WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := db.DeleteProduct(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteProduct(context.Background(), 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCreateProductTakesTheIdFromTheInsert(t *testing.T) {
+	db, mock := newMockStorage(t)
+	p := NewProduct("Desk", "DSK-1")
+
+	// Any other statement, such as a select lastval(), fails the test as unexpected.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`insert into product \(name, code, createdAt, updatedAt, status\) values\(\$1, \$2, \$3, \$3, \$4\) returning id, code`).
+		WithArgs("Desk", "DSK-1", p.CreatedAt, StatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(17, "DSK-1"))
+	mock.ExpectCommit()
+
+	created, err := db.CreateProduct(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Id != 17 {
+		t.Errorf("expected the id of the inserted row, got %d", created.Id)
+	}
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the storage calls.
+var tracer = otel.Tracer("apiGo/storage")
+
+// TraceCalls is a StorageMiddleware running every storage call inside a client span, a child of the span
+// of the request found in its context. The span is a no-op unless tracing is enabled.
+func TraceCalls(next Storage) Storage {
+	return &tracingStorage{next: next}
+}
+
+// tracingStorage is the Storage returned by TraceCalls.
+type tracingStorage struct {
+	next Storage
+}
+
+// start starts the span of the storage call method.
+func (o *tracingStorage) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "storage."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", method),
+		),
+	)
+}
+
+// endSpan records the error of the call, if any, and ends its span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// The Storage methods forward the call to next inside a span. Close has no context, so it isn't traced.
+
+func (o *tracingStorage) CreateProduct(ctx context.Context, p *Product) (*Product, error) {
+	ctx, span := o.start(ctx, "CreateProduct")
+	result, err := o.next.CreateProduct(ctx, p)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) CreateProductIfCodeAbsent(ctx context.Context, p *Product) (*Product, error) {
+	ctx, span := o.start(ctx, "CreateProductIfCodeAbsent")
+	result, err := o.next.CreateProductIfCodeAbsent(ctx, p)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) CreateProducts(ctx context.Context, products []*Product) ([]*Product, error) {
+	ctx, span := o.start(ctx, "CreateProducts")
+	result, err := o.next.CreateProducts(ctx, products)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) ReserveProductId(ctx context.Context) (int64, error) {
+	ctx, span := o.start(ctx, "ReserveProductId")
+	result, err := o.next.ReserveProductId(ctx)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) GetProducts(ctx context.Context, filter ProductFilter, page Page) ([]*Product, int64, error) {
+	ctx, span := o.start(ctx, "GetProducts")
+	products, total, err := o.next.GetProducts(ctx, filter, page)
+	endSpan(span, err)
+	return products, total, err
+}
+
+func (o *tracingStorage) SearchProducts(ctx context.Context, query string, page Page) ([]*Product, error) {
+	ctx, span := o.start(ctx, "SearchProducts")
+	result, err := o.next.SearchProducts(ctx, query, page)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) CountProducts(ctx context.Context, filter ProductFilter) (int64, error) {
+	ctx, span := o.start(ctx, "CountProducts")
+	result, err := o.next.CountProducts(ctx, filter)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) GetRandomProducts(ctx context.Context, n int) ([]*Product, error) {
+	ctx, span := o.start(ctx, "GetRandomProducts")
+	result, err := o.next.GetRandomProducts(ctx, n)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) GetNameCollisions(ctx context.Context) ([]*NameCollision, error) {
+	ctx, span := o.start(ctx, "GetNameCollisions")
+	result, err := o.next.GetNameCollisions(ctx)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) GetProductById(ctx context.Context, id int64) (*Product, error) {
+	ctx, span := o.start(ctx, "GetProductById")
+	result, err := o.next.GetProductById(ctx, id)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) GetProductByCode(ctx context.Context, code string) (*Product, error) {
+	ctx, span := o.start(ctx, "GetProductByCode")
+	result, err := o.next.GetProductByCode(ctx, code)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) GetProductsByIds(ctx context.Context, ids []int64) ([]*Product, error) {
+	ctx, span := o.start(ctx, "GetProductsByIds")
+	result, err := o.next.GetProductsByIds(ctx, ids)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) UpdateProduct(ctx context.Context, p *Product) (*Product, error) {
+	ctx, span := o.start(ctx, "UpdateProduct")
+	result, err := o.next.UpdateProduct(ctx, p)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) PatchProduct(ctx context.Context, id int64, patch ProductPatch) (*Product, error) {
+	ctx, span := o.start(ctx, "PatchProduct")
+	result, err := o.next.PatchProduct(ctx, id, patch)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) ProductExists(ctx context.Context, id int64) (bool, error) {
+	ctx, span := o.start(ctx, "ProductExists")
+	result, err := o.next.ProductExists(ctx, id)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) SetProductStatus(ctx context.Context, id int64, status string) (*Product, error) {
+	ctx, span := o.start(ctx, "SetProductStatus")
+	result, err := o.next.SetProductStatus(ctx, id, status)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) LockProduct(ctx context.Context, id int64, holder string, ttl time.Duration) (*ProductLock, error) {
+	ctx, span := o.start(ctx, "LockProduct")
+	result, err := o.next.LockProduct(ctx, id, holder, ttl)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) GetProductLock(ctx context.Context, id int64) (*ProductLock, error) {
+	ctx, span := o.start(ctx, "GetProductLock")
+	result, err := o.next.GetProductLock(ctx, id)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) NormalizeCodes(ctx context.Context) (*CodeNormalization, error) {
+	ctx, span := o.start(ctx, "NormalizeCodes")
+	result, err := o.next.NormalizeCodes(ctx)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) ReplaceSubstring(ctx context.Context, field, from, to string) ([]*Product, error) {
+	ctx, span := o.start(ctx, "ReplaceSubstring")
+	result, err := o.next.ReplaceSubstring(ctx, field, from, to)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) DeleteProduct(ctx context.Context, id int64) error {
+	ctx, span := o.start(ctx, "DeleteProduct")
+	err := o.next.DeleteProduct(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+func (o *tracingStorage) MergeProducts(ctx context.Context, keepId, mergeId int64) (*Product, error) {
+	ctx, span := o.start(ctx, "MergeProducts")
+	result, err := o.next.MergeProducts(ctx, keepId, mergeId)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) AddProductViews(ctx context.Context, views map[int64]int64) error {
+	ctx, span := o.start(ctx, "AddProductViews")
+	err := o.next.AddProductViews(ctx, views)
+	endSpan(span, err)
+	return err
+}
+
+func (o *tracingStorage) ClaimProducts(ctx context.Context, worker string, n int) ([]*Product, error) {
+	ctx, span := o.start(ctx, "ClaimProducts")
+	result, err := o.next.ClaimProducts(ctx, worker, n)
+	endSpan(span, err)
+	return result, err
+}
+
+func (o *tracingStorage) Ping(ctx context.Context) error {
+	ctx, span := o.start(ctx, "Ping")
+	err := o.next.Ping(ctx)
+	endSpan(span, err)
+	return err
+}
+
+func (o *tracingStorage) Close() error {
+	return o.next.Close()
+}
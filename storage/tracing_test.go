@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recorder records the spans of the tests. The tracer of the package is bound to the first global
+// provider set, so every test, and every run of it, shares this one.
+var recorder = func() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+}()
+
+// endedSpans returns the spans recorded in the trace of parent.
+func endedSpans(parent trace.Span) []sdktrace.ReadOnlySpan {
+	spans := make([]sdktrace.ReadOnlySpan, 0)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == parent.SpanContext().TraceID() {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func TestTraceCallsRecordsChildSpans(t *testing.T) {
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	_, err := Wrap(NewMemStorage(), TraceCalls).GetProductById(ctx, 9)
+	parent.End()
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	spans := endedSpans(parent)
+	if len(spans) != 2 {
+		t.Fatalf("expected the request and storage spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "storage.GetProductById" {
+		t.Errorf("unexpected span name %q", span.Name())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("the storage span isn't a child of the request span")
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("expected an error status, got %v", span.Status())
+	}
+}